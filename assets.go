@@ -0,0 +1,85 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+//publicFS holds the templates and static files of the browser UI, embedded so the server does not depend on the
+// directory it is started from
+//
+//go:embed public
+var publicFS embed.FS
+
+//uiFS returns the files of the browser UI: templates at its root and static files under static/. Files in dir, laid
+// out the same, take precedence over the embedded ones, so the UI can be customized one file at a time. An empty dir,
+// or one that does not exist, leaves the embedded files alone.
+func uiFS(dir string) (fs.FS, error) {
+	embedded, err := fs.Sub(publicFS, "public")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return embedded, nil
+	}
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return embedded, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read UI directory -> %s", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("UI directory %s is not a directory", dir)
+	}
+	return overlayFS{os.DirFS(dir), embedded}, nil
+}
+
+//overlayFS is a file system whose files are those of its first file system that has them
+type overlayFS []fs.FS
+
+//Open implements fs.FS
+func (o overlayFS) Open(name string) (fs.File, error) {
+	for _, fsys := range o[:len(o)-1] {
+		f, err := fsys.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return o[len(o)-1].Open(name)
+}
+
+//Glob implements fs.GlobFS, returning the names matching pattern in any of the file systems once
+func (o overlayFS) Glob(pattern string) ([]string, error) {
+	seen := map[string]bool{}
+	var names []string
+	for _, fsys := range o {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+//parseTemplates parses the templates of the browser UI in fsys, see uiFS. Each is named after its file, e.g.
+// gists.html.
+func parseTemplates(fsys fs.FS) (*template.Template, error) {
+	templates, err := template.New("").Funcs(template.FuncMap{"highlight": highlight}).ParseFS(fsys, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse templates -> %s", err)
+	}
+	return templates, nil
+}
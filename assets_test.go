@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//publicUI returns the embedded files of the browser UI
+func publicUI(t *testing.T) fs.FS {
+	files, err := uiFS("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func Test_uiFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "static"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	overrides := map[string]string{
+		"oauth.html":       `<p>Welcome to the team server</p><a href="{{ .URLL }}">Log in</a>`,
+		"static/style.css": "body { color: teal; }",
+	}
+	for name, content := range overrides {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		dir      string
+		file     string
+		want     string
+		template string
+	}{
+		{"embedded template", "", "oauth.html", "Login with github", "oauth.html"},
+		{"embedded static file", "", "static/style.css", "font-family", ""},
+		{"missing directory", filepath.Join(dir, "missing"), "oauth.html", "Login with github", "oauth.html"},
+		{"overridden template", dir, "oauth.html", "team server", "oauth.html"},
+		{"overridden static file", dir, "static/style.css", "teal", ""},
+		{"template that is not overridden", dir, "gists.html", `template "header"`, "gists.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := uiFS(tt.dir)
+			if err != nil {
+				t.Fatalf("uiFS() error = %v", err)
+			}
+			data, err := fs.ReadFile(files, tt.file)
+			if err != nil || !strings.Contains(string(data), tt.want) {
+				t.Errorf("uiFS() has %s = %s, %v, want it to contain %s", tt.file, data, err, tt.want)
+			}
+
+			if tt.template == "" {
+				recorder := httptest.NewRecorder()
+				http.FileServer(http.FS(files)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+tt.file, nil))
+				if !strings.Contains(recorder.Body.String(), tt.want) {
+					t.Errorf("GET /%s = %s, want it to contain %s", tt.file, recorder.Body, tt.want)
+				}
+				return
+			}
+			templates, err := parseTemplates(files)
+			if err != nil {
+				t.Fatalf("parseTemplates() error = %v", err)
+			}
+			if templates.Lookup(tt.template) == nil {
+				t.Errorf("parseTemplates() has no %s", tt.template)
+			}
+		})
+	}
+}
+
+func Test_uiFS_notADirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ui")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uiFS(file); err == nil {
+		t.Errorf("uiFS() of a file should fail")
+	}
+}
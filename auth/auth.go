@@ -1,13 +1,17 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/martinomburajr/gist/config"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 var (
@@ -28,8 +32,18 @@ var (
 	//AuthURL is the final URL used to perform a login
 	AuthURL = fmt.Sprintf("%s?client_id=%s&redirect_uri=%s", BaseURL, ClientID, RedirectURI)
 
+	//TokenEnv is the environment variable an AccessToken can be supplied through instead of the OAuth flow, e.g. a
+	// personal access token with the gist scope
+	TokenEnv = "GIST_TOKEN"
+
+	//TokenURL is where RedirectHandler exchanges the OAuth code for an AccessToken
+	TokenURL = "https://github.com/login/oauth/access_token"
+
+	//UserURL is where RedirectHandler looks up the user an AccessToken belongs to
+	UserURL = "https://api.github.com/user"
+
 	//Session is a singleton variable that holds all authentication and config based information for a session to
-	// succeed.
+	// succeed. It is the session of the commands, the server keeps a WebSession per browser, see Sessions.
 	Session = SessionObj{}
 )
 
@@ -41,6 +55,9 @@ func CreateOAuth2AuthorizationRequest() error {
 	}
 
 	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
 	defer resp.Body.Close()
 
 	data, err := ioutil.ReadAll(resp.Body)
@@ -52,44 +69,142 @@ func CreateOAuth2AuthorizationRequest() error {
 	return nil
 }
 
-//RedirectHandler is hit after the CreateAuth2AuthorizationRequest begins the OAuth2 Transaction.
-//The response should contain
-func RedirectHandler(w http.ResponseWriter, r *http.Request) {
-	log.Print("I AM HERE REDIRECTED")
-	err := r.ParseForm()
+//RedirectHandler is hit after the CreateAuth2AuthorizationRequest begins the OAuth2 Transaction. Callbacks whose
+// state was not set on the browser by LoginURL are refused, see VerifyState. It exchanges the code GitHub redirected
+// the browser with for an AccessToken, starts a WebSession for the user it belongs to and
+// redirects to the login page.
+func RedirectHandler(sessions *Sessions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := VerifyState(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		code := r.FormValue("code")
+		if code == "" {
+			http.Error(w, "missing code", http.StatusBadRequest)
+			return
+		}
+
+		token, user, err := Exchange(r.Context(), code)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "could not log in with GitHub", http.StatusBadGateway)
+			return
+		}
+
+		if _, err := sessions.Start(w, r, token, user); err != nil {
+			log.Print(err)
+			http.Error(w, "could not log in", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+//LogoutHandler ends the WebSession of the browser and redirects to the login page
+func LogoutHandler(sessions *Sessions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := sessions.End(w, r); err != nil {
+			log.Print(err)
+			http.Error(w, "could not log out", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+//SetRedirectURI sets the RedirectURI GitHub sends the browser back to after logging in, and the AuthURL using it,
+// e.g. for a server listening on another port than config.PORT
+func SetRedirectURI(uri string) {
+	RedirectURI = uri
+	AuthURL = fmt.Sprintf("%s?client_id=%s&redirect_uri=%s", BaseURL, ClientID, url.QueryEscape(RedirectURI))
+}
+
+//Exchange exchanges the code GitHub redirected the browser with for an AccessToken, and returns it along with the
+// login of the user it belongs to
+func Exchange(ctx context.Context, code string) (string, string, error) {
+	token, err := exchangeCode(ctx, code)
+	if err != nil {
+		return "", "", err
+	}
+	user, err := currentUser(ctx, token)
 	if err != nil {
-		fmt.Fprintf(os.Stdout, "could not parse query: %s", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		return "", "", err
 	}
-	code := r.FormValue("code")
+	return token, user, nil
+}
 
-	reqURL := fmt.Sprintf("https://github.com/login/oauth/access_token?client_id=%s&client_secret=%s&code=%s", ClientID, ClientSecret, code)
-	req, err := http.NewRequest(http.MethodPost, reqURL, nil)
+//SaveToken writes token to the file at path, readable only by the user, so the commands can use it, see LoadToken
+func SaveToken(path, token string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("could not save token -> %s", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return fmt.Errorf("could not save token -> %s", err)
+	}
+	return nil
+}
+
+//LoadToken returns the token saved at path by SaveToken. It returns an error satisfying os.IsNotExist if there is
+// none.
+func LoadToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		fmt.Fprintf(os.Stdout, "could not retrieve http request: %s", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		return "", err
 	}
+	return strings.TrimSpace(string(data)), nil
+}
 
-	req.Header.Set(http.CanonicalHeaderKey("accept"), "application/json")
-	req.Header.Set("X-OAuth-Scopes", "gists")
+//exchangeCode exchanges an OAuth code for an AccessToken at TokenURL
+func exchangeCode(ctx context.Context, code string) (string, error) {
+	form := url.Values{"client_id": {ClientID}, "client_secret": {ClientSecret}, "code": {code}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, TokenURL+"?"+form.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		fmt.Fprintf(os.Stdout, "could not send HTTP request: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+		return "", fmt.Errorf("could not exchange code -> %s", err)
 	}
-
 	defer res.Body.Close()
+
 	// Parse the request body into the `OAuthAccessResponse` struct
 	var t OAuthAccessResponse
 	if err := json.NewDecoder(res.Body).Decode(&t); err != nil {
-		fmt.Fprintf(os.Stdout, "could not parse JSON response: %s", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		return "", fmt.Errorf("could not parse access token response -> %s", err)
+	}
+	if t.AccessToken == "" {
+		return "", fmt.Errorf("could not exchange code -> %s %s", res.Status, t.Error)
 	}
-	Session.AccessToken = t.AccessToken
+	return t.AccessToken, nil
+}
 
-	w.WriteHeader(http.StatusFound)
-	w.Write([]byte("OK"))
+//currentUser returns the login of the GitHub user token belongs to, from UserURL
+func currentUser(ctx context.Context, token string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, UserURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "token "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not retrieve user -> %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not retrieve user -> %s", res.Status)
+	}
+
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
+		return "", fmt.Errorf("could not parse user -> %s", err)
+	}
+	return user.Login, nil
 }
 
 //SessionObj is a type that contains session based information for authentication based actions to work
@@ -101,4 +216,39 @@ type SessionObj struct {
 //OAuthAccessResponse embodies a response from the GitHub OAuth server with the AccessToken if authorized.
 type OAuthAccessResponse struct {
 	AccessToken string `json:"access_token"`
+
+	//Error is set instead of AccessToken if the code could not be exchanged, e.g. bad_verification_code
+	Error string `json:"error"`
+}
+
+//TokenTransport is an http.RoundTripper that authenticates every request it performs with an OAuth AccessToken.
+type TokenTransport struct {
+	//Token is the AccessToken to send. If it is empty, the AccessToken set on the context of the request by WithToken
+	// is used, and failing that the one held by Session at the time of the request.
+	Token string
+
+	//Base is the RoundTripper used to perform the request. http.DefaultTransport is used if it is nil.
+	Base http.RoundTripper
+}
+
+//RoundTrip implements http.RoundTripper
+func (t *TokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	token := t.Token
+	if token == "" {
+		token = TokenFromContext(req.Context())
+	}
+	if token == "" {
+		token = Session.AccessToken
+	}
+	if token == "" {
+		return base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+	return base.RoundTrip(req)
 }
@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//CookieName is the name of the cookie holding the id of the WebSession of a browser
+const CookieName = "gist_session"
+
+//SessionTTL is how long a login to the server lasts
+const SessionTTL = 7 * 24 * time.Hour
+
+//ErrNoSession is returned for a WebSession that does not exist or has expired
+var ErrNoSession = errors.New("not logged in")
+
+//WebSession is the login of one browser to the server, mapping the id in its cookie to the AccessToken and GitHub
+// user it logged in with
+type WebSession struct {
+	ID          string    `json:"id"`
+	AccessToken string    `json:"access_token"`
+	User        string    `json:"user"`
+	Expires     time.Time `json:"expires"`
+}
+
+//expired reports whether the session has expired at now
+func (s *WebSession) expired(now time.Time) bool {
+	return !now.Before(s.Expires)
+}
+
+//Store keeps the WebSessions of the server. Get returns ErrNoSession for a session that does not exist or has expired.
+type Store interface {
+	Get(id string) (*WebSession, error)
+	Save(session *WebSession) error
+	Delete(id string) error
+
+	//Prune removes every expired session
+	Prune() error
+}
+
+//MemoryStore is a Store that keeps sessions in memory, they are lost when the server stops
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]WebSession
+}
+
+//NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]WebSession{}}
+}
+
+//Get implements Store
+func (s *MemoryStore) Get(id string) (*WebSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNoSession
+	}
+	if session.expired(time.Now()) {
+		delete(s.sessions, id)
+		return nil, ErrNoSession
+	}
+	return &session, nil
+}
+
+//Save implements Store
+func (s *MemoryStore) Save(session *WebSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+//Delete implements Store, deleting a session that does not exist is not an error
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+//Prune implements Store
+func (s *MemoryStore) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range s.sessions {
+		if session.expired(now) {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+//FileStore is a Store that keeps each session in its own file within Dir, so logins survive a restart of the server.
+// Files are named after a hash of the session id, which is never written in the clear, and only the user can read
+// them.
+type FileStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+//NewFileStore returns a FileStore that keeps its sessions in dir
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+//Get implements Store
+func (s *FileStore) Get(id string) (*WebSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(id)
+	session, err := readSession(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.ID != id {
+		return nil, ErrNoSession
+	}
+	if session.expired(time.Now()) {
+		os.Remove(path)
+		return nil, ErrNoSession
+	}
+	return session, nil
+}
+
+//Save implements Store
+func (s *FileStore) Save(session *WebSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("could not create sessions directory -> %s", err)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(s.Dir, ".session-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(session.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+//Delete implements Store, deleting a session that does not exist is not an error
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//Prune implements Store, unreadable session files are removed too
+func (s *FileStore) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, path := range paths {
+		session, err := readSession(path)
+		if err == nil && !session.expired(now) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+//path returns the file the session with the given id is stored in
+func (s *FileStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+//readSession decodes the session stored at path
+func readSession(path string) (*WebSession, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var session WebSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("could not parse session %s -> %s", path, err)
+	}
+	return &session, nil
+}
+
+//Sessions ties browsers to the WebSessions in Store through the CookieName cookie. The cookie is HttpOnly and
+// SameSite=Lax, so other sites can neither read it nor send it along with requests that change gists.
+type Sessions struct {
+	Store Store
+
+	//TTL is how long a session lasts after logging in
+	TTL time.Duration
+}
+
+//NewSessions returns Sessions kept in store that last SessionTTL
+func NewSessions(store Store) *Sessions {
+	return &Sessions{Store: store, TTL: SessionTTL}
+}
+
+//Start creates a session for the user logged in with token and sets its cookie on w. Expired sessions are pruned
+// from the Store on the way.
+func (s *Sessions) Start(w http.ResponseWriter, r *http.Request, token, user string) (*WebSession, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("could not create session -> %s", err)
+	}
+	session := &WebSession{
+		ID:          base64.RawURLEncoding.EncodeToString(id),
+		AccessToken: token,
+		User:        user,
+		Expires:     time.Now().Add(s.TTL),
+	}
+	if err := s.Store.Prune(); err != nil {
+		return nil, fmt.Errorf("could not prune sessions -> %s", err)
+	}
+	if err := s.Store.Save(session); err != nil {
+		return nil, fmt.Errorf("could not save session -> %s", err)
+	}
+	setCookie(w, r, session.ID, session.Expires)
+	return session, nil
+}
+
+//Current returns the session of the browser that sent r, or ErrNoSession if it is not logged in
+func (s *Sessions) Current(r *http.Request) (*WebSession, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrNoSession
+	}
+	return s.Store.Get(cookie.Value)
+}
+
+//End logs the browser that sent r out, deleting its session and its cookie
+func (s *Sessions) End(w http.ResponseWriter, r *http.Request) error {
+	setCookie(w, r, "", time.Unix(0, 0))
+	cookie, err := r.Cookie(CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	return s.Store.Delete(cookie.Value)
+}
+
+//setCookie sets the session cookie to value until expires, it is only sent over TLS if r came over TLS
+func setCookie(w http.ResponseWriter, r *http.Request, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+//tokenKey is the context key of the AccessToken set by WithToken
+type tokenKey struct{}
+
+//WithToken returns a copy of ctx carrying token, which TokenTransport authenticates the requests made with the
+// context with, e.g. to act on behalf of the user of a WebSession
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+//TokenFromContext returns the AccessToken set on ctx by WithToken, or "" if there is none
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
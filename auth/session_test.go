@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	stores := []struct {
+		name  string
+		store Store
+	}{
+		{"memory", NewMemoryStore()},
+		{"file", NewFileStore(t.TempDir())},
+	}
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			alice := &WebSession{ID: "alice", AccessToken: "a", User: "alice", Expires: time.Now().Add(time.Hour)}
+			expired := &WebSession{ID: "bob", AccessToken: "b", User: "bob", Expires: time.Now().Add(-time.Hour)}
+			for _, session := range []*WebSession{alice, expired} {
+				if err := s.store.Save(session); err != nil {
+					t.Fatalf("Store.Save() error = %v", err)
+				}
+			}
+
+			got, err := s.store.Get("alice")
+			if err != nil || got.AccessToken != "a" || got.User != "alice" {
+				t.Errorf("Store.Get(alice) = %+v, %v, want the session of alice", got, err)
+			}
+			if _, err := s.store.Get("bob"); err != ErrNoSession {
+				t.Errorf("Store.Get(bob) error = %v, want %v for an expired session", err, ErrNoSession)
+			}
+			if _, err := s.store.Get("carol"); err != ErrNoSession {
+				t.Errorf("Store.Get(carol) error = %v, want %v", err, ErrNoSession)
+			}
+
+			if err := s.store.Prune(); err != nil {
+				t.Errorf("Store.Prune() error = %v", err)
+			}
+			if err := s.store.Delete("alice"); err != nil {
+				t.Errorf("Store.Delete() error = %v", err)
+			}
+			if _, err := s.store.Get("alice"); err != ErrNoSession {
+				t.Errorf("Store.Get(alice) after Delete error = %v, want %v", err, ErrNoSession)
+			}
+			if err := s.store.Delete("alice"); err != nil {
+				t.Errorf("Store.Delete() of a deleted session error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSessions(t *testing.T) {
+	sessions := NewSessions(NewMemoryStore())
+	recorder := httptest.NewRecorder()
+	started, err := sessions.Start(recorder, httptest.NewRequest(http.MethodGet, "/", nil), "a", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := recorder.Result().Cookies()[0]
+	if cookie.Name != CookieName || cookie.Value != started.ID || !cookie.HttpOnly {
+		t.Errorf("Sessions.Start() set %+v, want an HttpOnly %s cookie", cookie, CookieName)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	if got, err := sessions.Current(req); err != nil || got.User != "alice" {
+		t.Errorf("Sessions.Current() = %+v, %v, want the session of alice", got, err)
+	}
+	if _, err := sessions.Current(httptest.NewRequest(http.MethodGet, "/", nil)); err != ErrNoSession {
+		t.Errorf("Sessions.Current() without a cookie error = %v, want %v", err, ErrNoSession)
+	}
+
+	recorder = httptest.NewRecorder()
+	if err := sessions.End(recorder, req); err != nil {
+		t.Fatalf("Sessions.End() error = %v", err)
+	}
+	if cleared := recorder.Result().Cookies()[0]; cleared.Value != "" || cleared.MaxAge >= 0 {
+		t.Errorf("Sessions.End() set %+v, want the cookie removed", cleared)
+	}
+	if _, err := sessions.Current(req); err != ErrNoSession {
+		t.Errorf("Sessions.Current() after End error = %v, want %v", err, ErrNoSession)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token" && r.FormValue("code") == "good":
+			fmt.Fprint(w, `{"access_token": "a"}`)
+		case r.URL.Path == "/token":
+			fmt.Fprint(w, `{"error": "bad_verification_code"}`)
+		case r.URL.Path == "/user" && r.Header.Get("Authorization") == "token a":
+			fmt.Fprint(w, `{"login": "alice"}`)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer github.Close()
+	tokenURL, userURL := TokenURL, UserURL
+	defer func() { TokenURL, UserURL = tokenURL, userURL }()
+	TokenURL, UserURL = github.URL+"/token", github.URL+"/user"
+
+	tests := []struct {
+		name       string
+		code       string
+		state      string
+		cookie     bool
+		wantStatus int
+		wantUser   string
+	}{
+		{"logged in", "good", "", true, http.StatusFound, "alice"},
+		{"bad code", "bad", "", true, http.StatusBadGateway, ""},
+		{"no code", "", "", true, http.StatusBadRequest, ""},
+		{"no state cookie", "good", "", false, http.StatusBadRequest, ""},
+		{"forged state", "good", "forged", true, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			login := httptest.NewRecorder()
+			loginURL, err := LoginURL(login, httptest.NewRequest(http.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("LoginURL() error = %v", err)
+			}
+			state := tt.state
+			if state == "" {
+				parsed, _ := url.Parse(loginURL)
+				state = parsed.Query().Get("state")
+			}
+
+			sessions := NewSessions(NewMemoryStore())
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet,
+				"/auth/github/callback?code="+tt.code+"&state="+url.QueryEscape(state), nil)
+			if tt.cookie {
+				req.AddCookie(login.Result().Cookies()[0])
+			}
+			RedirectHandler(sessions)(recorder, req)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("RedirectHandler() = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			var started *http.Cookie
+			for _, cookie := range recorder.Result().Cookies() {
+				switch {
+				case cookie.Name == CookieName:
+					started = cookie
+				case cookie.Name == StateCookieName && cookie.MaxAge >= 0:
+					t.Errorf("RedirectHandler() kept the state cookie %+v, want it removed", cookie)
+				}
+			}
+			if tt.wantUser == "" {
+				if started != nil {
+					t.Errorf("RedirectHandler() set %+v, want no session", started)
+				}
+				return
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(started)
+			session, err := sessions.Current(req)
+			if err != nil || session.User != tt.wantUser || session.AccessToken != "a" {
+				t.Errorf("RedirectHandler() started %+v, %v, want a session of %s", session, err, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestTokenTransport_RoundTrip(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+	session := Session
+	defer func() { Session = session }()
+	Session.AccessToken = "server"
+
+	tests := []struct {
+		name      string
+		transport *TokenTransport
+		context   string
+		want      string
+	}{
+		{"token", &TokenTransport{Token: "fixed"}, "alice", "token fixed"},
+		{"context", &TokenTransport{}, "alice", "token alice"},
+		{"session", &TokenTransport{}, "", "token server"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, server.URL, nil)
+			req.RequestURI = ""
+			if tt.context != "" {
+				req = req.WithContext(WithToken(req.Context(), tt.context))
+			}
+			resp, err := (&http.Client{Transport: tt.transport}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got != tt.want {
+				t.Errorf("TokenTransport sent Authorization %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+//StateCookieName is the name of the cookie binding a login attempt to the browser that started it, see LoginURL
+const StateCookieName = "gist_oauth_state"
+
+//StateTTL is how long a login attempt started by LoginURL can be completed
+const StateTTL = 10 * time.Minute
+
+//ErrState is returned by VerifyState for a callback that does not belong to a login attempt of the browser, e.g. one
+// a third party sent the browser to with their own code
+var ErrState = errors.New("the login was not started by this browser, start it again")
+
+//LoginURL returns AuthURL with a random state for a new login attempt, and sets the state on w as a cookie that
+// VerifyState checks the callback against
+func LoginURL(w http.ResponseWriter, r *http.Request) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not start login -> %s", err)
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     StateCookieName,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(StateTTL / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return AuthURL + "&state=" + url.QueryEscape(state), nil
+}
+
+//VerifyState returns ErrState unless the state GitHub redirected the browser back with is the one LoginURL set on it.
+// The state cookie is removed either way, so a state is used at most once.
+func VerifyState(w http.ResponseWriter, r *http.Request) error {
+	cookie, err := r.Cookie(StateCookieName)
+	http.SetCookie(w, &http.Cookie{Name: StateCookieName, Path: "/", MaxAge: -1, HttpOnly: true,
+		Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+	state := r.FormValue("state")
+	if err != nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return ErrState
+	}
+	return nil
+}
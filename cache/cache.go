@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Entry is a single cached GitHub API response together with the validators needed to revalidate it.
+type Entry struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag"`
+	LastModified string      `json:"last_modified"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	StoredAt     time.Time   `json:"stored_at"`
+
+	//Auth identifies the credentials the response was fetched with, see AuthKey. Entries are only served to requests
+	// carrying the same credentials, so secret gists of one user are never shown to another.
+	Auth string `json:"auth,omitempty"`
+
+	//AccessedAt is taken from the modification time of the entry on disk and is used for eviction
+	AccessedAt time.Time `json:"-"`
+
+	//Size is the size of the entry on disk
+	Size int64 `json:"-"`
+}
+
+//Store is an on-disk cache of GitHub API responses keyed by request URL and credentials. Each entry lives in its own
+// file within Dir. Once the total size of the entries exceeds MaxBytes the least recently used ones are removed.
+type Store struct {
+	Dir      string
+	MaxBytes int64
+
+	mu sync.Mutex
+}
+
+//NewStore returns a Store that keeps its entries in dir and holds at most maxBytes. A maxBytes of zero or less means
+// the store is unbounded.
+func NewStore(dir string, maxBytes int64) *Store {
+	return &Store{
+		Dir:      dir,
+		MaxBytes: maxBytes,
+	}
+}
+
+//Get returns the entry stored for url and the credentials auth. It returns an error satisfying os.IsNotExist if there
+// is no such entry.
+func (s *Store) Get(url, auth string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(url, auth)
+	entry, err := readEntry(path)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		entry.AccessedAt = now
+	}
+	return entry, nil
+}
+
+//Put stores the entry, replacing any previous entry for the same URL and Auth, and evicts old entries if the store
+// has grown past MaxBytes.
+func (s *Store) Put(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("could not create cache directory -> %s", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(s.Dir, ".entry-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path(entry.URL, entry.Auth)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return s.prune()
+}
+
+//Remove deletes the entry stored for url and the credentials auth. Removing an entry that does not exist is not an
+// error.
+func (s *Store) Remove(url, auth string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(url, auth))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//List returns every entry in the store, most recently used first.
+func (s *Store) List() ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list()
+}
+
+//Clear removes every entry in the store.
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+//prune removes the least recently used entries until the store fits within MaxBytes. The caller must hold s.mu.
+func (s *Store) prune() error {
+	if s.MaxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	for i := len(entries) - 1; i >= 0 && total > s.MaxBytes; i-- {
+		if err := os.Remove(s.path(entries[i].URL, entries[i].Auth)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= entries[i].Size
+	}
+	return nil
+}
+
+//list reads every entry in the store, most recently used first. Unreadable entries are skipped. The caller must hold
+// s.mu.
+func (s *Store) list() ([]*Entry, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(paths))
+	for _, path := range paths {
+		entry, err := readEntry(path)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].AccessedAt.After(entries[j].AccessedAt)
+	})
+	return entries, nil
+}
+
+//path returns the file an entry for url and the credentials auth is stored in.
+func (s *Store) path(url, auth string) string {
+	sum := sha256.Sum256([]byte(auth + " " + url))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+//AuthKey returns the Auth of entries for req, a hash of its Authorization header, or "" if it carries none. The token
+// itself is never written to disk.
+func AuthKey(req *http.Request) string {
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(authorization))
+	return hex.EncodeToString(sum[:])
+}
+
+//readEntry decodes the entry stored at path.
+func readEntry(path string) (*Entry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("corrupt cache entry %s -> %s", filepath.Base(path), err)
+	}
+	entry.AccessedAt = info.ModTime()
+	entry.Size = info.Size()
+	return &entry, nil
+}
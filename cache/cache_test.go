@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransport_RoundTrip(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"id":"abc"}`)
+	}))
+	defer server.Close()
+
+	store := NewStore(t.TempDir(), 0)
+	client := &http.Client{Transport: &Transport{Store: store}}
+
+	tests := []struct {
+		name      string
+		method    string
+		wantBody  string
+		fromCache bool
+	}{
+		{"first request is stored", http.MethodGet, `{"id":"abc"}`, false},
+		{"second request is revalidated", http.MethodGet, `{"id":"abc"}`, true},
+		{"patch invalidates the entry", http.MethodPatch, `{"id":"abc"}`, false},
+		{"request after invalidation is not conditional", http.MethodGet, `{"id":"abc"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+"/gists/abc", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Transport.RoundTrip() status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("Transport.RoundTrip() body = %s, want %s", body, tt.wantBody)
+			}
+			if got := resp.Header.Get(FromCacheHeader) != ""; got != tt.fromCache {
+				t.Errorf("Transport.RoundTrip() from cache = %v, want %v", got, tt.fromCache)
+			}
+		})
+	}
+	if hits != len(tests) {
+		t.Errorf("server was hit %d times, want %d", hits, len(tests))
+	}
+}
+
+func TestTransport_RoundTrip_credentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, `{"owner":%q}`, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	store := NewStore(t.TempDir(), 0)
+	client := &http.Client{Transport: &Transport{Store: store}}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantBody      string
+		fromCache     bool
+	}{
+		{"alice is stored", "token alice", `{"owner":"token alice"}`, false},
+		{"alice is revalidated", "token alice", `{"owner":"token alice"}`, true},
+		{"bob does not get the response of alice", "token bob", `{"owner":"token bob"}`, false},
+		{"anonymous does not get the response of alice", "", `{"owner":""}`, false},
+		{"bob is revalidated", "token bob", `{"owner":"token bob"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/gists/abc", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			if string(body) != tt.wantBody {
+				t.Errorf("Transport.RoundTrip() body = %s, want %s", body, tt.wantBody)
+			}
+			if got := resp.Header.Get(FromCacheHeader) != ""; got != tt.fromCache {
+				t.Errorf("Transport.RoundTrip() from cache = %v, want %v", got, tt.fromCache)
+			}
+		})
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Auth, "alice") || strings.Contains(e.Auth, "bob") {
+			t.Errorf("Store kept the token %s on disk", e.Auth)
+		}
+	}
+}
+
+func TestStore_Put(t *testing.T) {
+	store := NewStore(t.TempDir(), 600)
+	for i := 0; i < 5; i++ {
+		err := store.Put(&Entry{
+			URL:  fmt.Sprintf("https://api.github.com/gists/%d", i),
+			ETag: fmt.Sprintf(`"%d"`, i),
+			Body: make([]byte, 100),
+		})
+		if err != nil {
+			t.Fatalf("Store.Put() error = %v", err)
+		}
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	if total > store.MaxBytes {
+		t.Errorf("Store.Put() left %d bytes in the store, want at most %d", total, store.MaxBytes)
+	}
+	if len(entries) == 0 || len(entries) == 5 {
+		t.Errorf("Store.Put() kept %d entries, want some but not all to be evicted", len(entries))
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := store.List(); len(entries) != 0 {
+		t.Errorf("Store.Clear() left %d entries", len(entries))
+	}
+}
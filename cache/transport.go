@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//FromCacheHeader is set on responses whose body was served from the Store after GitHub answered 304 Not Modified
+const FromCacheHeader = "X-From-Cache"
+
+//Transport is an http.RoundTripper that makes GET requests conditional using the ETag and Last-Modified validators of
+// previously stored responses. When GitHub replies 304 Not Modified, which does not count against the rate limit,
+// the stored body is returned to the caller as a regular 200 OK response. Any other method invalidates the entry for
+// the requested URL.
+//
+// Entries are keyed on the Authorization header as well as the URL, so Transport must sit below the RoundTripper
+// authenticating the request, such as auth.TokenTransport, for the responses of different users to be kept apart.
+type Transport struct {
+	Store *Store
+
+	//Base is the RoundTripper used to perform the request. http.DefaultTransport is used if it is nil.
+	Base http.RoundTripper
+}
+
+//RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	url, auth := req.URL.String(), AuthKey(req)
+	if !isCacheable(req) {
+		if req.Method != http.MethodHead {
+			t.Store.Remove(url, auth)
+		}
+		return t.base().RoundTrip(req)
+	}
+
+	entry, err := t.Store.Get(url, auth)
+	if err == nil {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		return entry.response(req, resp), nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.Store.Put(&Entry{
+		URL:          url,
+		Auth:         auth,
+		ETag:         etag,
+		LastModified: lastModified,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		StoredAt:     time.Now(),
+	})
+	return resp, nil
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+//response rebuilds a 200 OK response for req from the entry. Headers from the 304 response, such as the current rate
+// limit, take precedence over the stored ones.
+func (e *Entry) response(req *http.Request, notModified *http.Response) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	for k, v := range notModified.Header {
+		header[k] = v
+	}
+	header.Del("Content-Length")
+	header.Set(FromCacheHeader, "1")
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+//isCacheable reports whether a request may be answered from, or stored in, the cache.
+func isCacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet && req.Header.Get("Range") == "" &&
+		!strings.Contains(req.Header.Get("Cache-Control"), "no-store")
+}
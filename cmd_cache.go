@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"github.com/martinomburajr/gist/cache"
+	"github.com/martinomburajr/gist/config"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+//cacheCommand implements `gist cache`, which lists the cached GitHub API responses or clears them.
+func cacheCommand(args []string) error {
+	fs := newFlagSet("cache", "[list|clear]")
+	dir := fs.String("dir", config.CacheDir(), "directory holding the response cache")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store := cache.NewStore(*dir, config.CacheMaxBytes)
+	switch fs.Arg(0) {
+	case "", "list":
+		entries, err := store.List()
+		if err != nil {
+			return err
+		}
+
+		var total int64
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "URL\tETAG\tSIZE\tSTORED\tLAST USED")
+		for _, e := range entries {
+			total += e.Size
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", e.URL, e.ETag, e.Size, e.StoredAt.Format(time.RFC3339),
+				e.AccessedAt.Format(time.RFC3339))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d entries, %d of %d bytes used in %s\n", len(entries), total, store.MaxBytes, store.Dir)
+		return nil
+	case "clear":
+		if err := store.Clear(); err != nil {
+			return err
+		}
+		fmt.Printf("cleared %s\n", store.Dir)
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown cache action %q", fs.Arg(0))
+	}
+}
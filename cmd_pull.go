@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/martinomburajr/gist/crypt"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/manifest"
+	"github.com/martinomburajr/gist/utils"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+//pullCommand implements `gist pull`, which downloads gists into a directory, one folder per gist named after its id.
+// The description, visibility, id and url of each gist are written into a sidecar next to every file, or into a
+// GOGIST header with -header, so the files can be pushed back. Files edited since they were last pulled or pushed are
+// only overwritten once confirmed. Encrypted gists are decrypted when one of the user's identities can, and kept
+// encrypted otherwise.
+func pullCommand(args []string) error {
+	fs := newFlagSet("pull", "[flags] [id...]")
+	dir := fs.String("dir", ".", "directory to download the gists into")
+	all := fs.Bool("all", false, "pull every gist of the logged in user")
+	header := fs.Bool("header", false, "write the metadata as a GOGIST header in files whose language is known, "+
+		"rather than as a sidecar")
+	force := fs.Bool("force", false, "overwrite local edits without asking")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ids := fs.Args()
+	if *all {
+		if err := requireLogin(); err != nil {
+			return err
+		}
+		list, err := gists.List()
+		if err != nil {
+			return err
+		}
+		for _, g := range list {
+			ids = append(ids, g.ID)
+		}
+	}
+	if len(ids) == 0 {
+		fs.Usage()
+		return fmt.Errorf("no gists to pull, give their ids or -all")
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	root, err := manifest.FindRoot(*dir)
+	if err != nil {
+		return err
+	}
+	m, err := manifest.Load(root)
+	if err != nil {
+		return err
+	}
+	settings, err := loadSettings(*dir)
+	if err != nil {
+		return err
+	}
+	keys, err := crypt.LoadKeys(settings)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, id := range ids {
+		g := &gists.GistFile{}
+		resp, err := g.Retrieve(id)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%s", resp.Status)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not pull %s -> %s\n", id, err)
+			failed++
+			continue
+		}
+
+		encrypt, err := keys.Decrypt(g)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "keeping %s encrypted -> %s\n", id, err)
+		}
+		for _, file := range g.Files {
+			if err := pullFile(m, filepath.Join(*dir, g.ID), g, file, encrypt, *header, *force); err != nil {
+				fmt.Fprintf(os.Stderr, "could not pull %s of %s -> %s\n", file.Filename, id, err)
+				failed++
+			}
+		}
+	}
+
+	if err := m.Save(); err != nil {
+		return fmt.Errorf("could not save manifest -> %s", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d files could not be pulled", failed)
+	}
+	return nil
+}
+
+//pulledEncrypt returns how the file at path is encrypted again when it is pushed, given how its gist was encrypted,
+// see crypt.Keys.Decrypt. The profile a gist was encrypted for cannot be told, so the profile an existing file at path
+// names is kept rather than replaced with gists.EncryptDefault.
+func pulledEncrypt(path, encrypt string) string {
+	if encrypt != gists.EncryptDefault {
+		return encrypt
+	}
+	existing, err := (&gists.GistParser{Filepath: path}).GetEncrypt()
+	if err != nil || existing == "" || existing == gists.EncryptPassphrase {
+		return encrypt
+	}
+	return existing
+}
+
+//pullFile writes a single file of g into dir along with its metadata, and records it in m. encrypt is how g was
+// encrypted before it was decrypted, see crypt.Keys.Decrypt, so the file is encrypted again when it is pushed.
+func pullFile(m *manifest.Manifest, dir string, g *gists.GistFile, file gists.GistFileBody, encrypt string, header,
+	force bool) error {
+	//file names come from the remote gist, make sure they cannot escape dir
+	if err := gists.ValidateFilename(file.Filename); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, file.Filename)
+	encrypt = pulledEncrypt(path, encrypt)
+
+	fields := [][2]string{
+		{"description", g.Description},
+		{"public", strconv.FormatBool(g.Public)},
+		{"id", g.ID},
+		{"url", g.URL},
+	}
+	if encrypt == gists.EncryptDefault {
+		fields = append(fields, [2]string{"encrypt", "true"})
+	} else if encrypt != "" {
+		fields = append(fields, [2]string{"encrypt", encrypt})
+	}
+	content := []byte(file.Content)
+	var sidecar []byte
+	if text, ok := gists.FormatHeader(path, append(fields, [2]string{"strip", "true"})); header && ok {
+		content = []byte(gists.InsertHeader(file.Content, text))
+	} else {
+		sidecar = gists.FormatSidecar(fields)
+	}
+
+	edited, err := localEdits(path, m.Get(path), content)
+	if err != nil {
+		return err
+	}
+	if edited && !force && !confirm(fmt.Sprintf("%s has local edits, overwrite it?", path)) {
+		fmt.Printf("kept %s\n", path)
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return err
+	}
+	if sidecar != nil {
+		if err := ioutil.WriteFile(path+gists.SidecarSuffix, sidecar, 0644); err != nil {
+			return err
+		}
+	}
+
+	result := utils.ScanFile(path, nil)
+	if result.Status != utils.ScanGistable {
+		return fmt.Errorf("pulled %s but it is not gistable -> %s", path, result.Error)
+	}
+	hash, err := manifest.Hash(result.Gist)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pulled %s %s\n", path, g.URL)
+	return m.Set(path, &manifest.Entry{
+		GistID:   g.ID,
+		URL:      g.URL,
+		Files:    []string{file.Filename},
+		Hash:     hash,
+		Revision: g.Revision,
+		SyncedAt: time.Now().UTC(),
+	})
+}
+
+//localEdits reports whether the file at path has changes that would be lost by writing content to it. A file last
+// synced as entry is edited if its gist no longer hashes the same, any other existing file if it differs from content.
+func localEdits(path string, entry *manifest.Entry, content []byte) (bool, error) {
+	current, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if entry == nil {
+		return !bytes.Equal(current, content), nil
+	}
+	result := utils.ScanFile(path, nil)
+	if result.Status != utils.ScanGistable {
+		return true, nil
+	}
+	hash, err := manifest.Hash(result.Gist)
+	if err != nil {
+		return false, err
+	}
+	return hash != entry.Hash, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/martinomburajr/gist/gists"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func Test_pulledEncrypt(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		encrypt  string
+		want     string
+	}{
+		{"not encrypted", "", "", ""},
+		{"passphrase", "team", gists.EncryptPassphrase, gists.EncryptPassphrase},
+		{"first pull", "", gists.EncryptDefault, gists.EncryptDefault},
+		{"profile kept", "team", gists.EncryptDefault, "team"},
+		{"default kept", "true", gists.EncryptDefault, gists.EncryptDefault},
+		{"was passphrase", gists.EncryptPassphrase, gists.EncryptDefault, gists.EncryptDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "notes.md")
+			if tt.existing != "" {
+				if err := ioutil.WriteFile(path, []byte("# Notes\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				sidecar := gists.FormatSidecar([][2]string{{"id", "abc"}, {"encrypt", tt.existing}})
+				if err := ioutil.WriteFile(path+gists.SidecarSuffix, sidecar, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := pulledEncrypt(path, tt.encrypt); got != tt.want {
+				t.Errorf("pulledEncrypt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/crypt"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/manifest"
+	"github.com/martinomburajr/gist/secrets"
+	"github.com/martinomburajr/gist/utils"
+	"net/http"
+	"os"
+	"time"
+)
+
+//pushCommand implements `gist push`, which creates a gist for every gistable file given, or found within the given
+// directories. Files that were pushed before, and so have an 'id' in their header or an entry in the project's
+// manifest, update their gist instead. With -dry-run the plan is printed as JSON and nothing is sent.
+func pushCommand(args []string) error {
+	fs := newFlagSet("push", "[flags] path...")
+	opts := scanFlags(fs)
+	fs.BoolVar(&opts.Strip, "strip", false, "remove the GOGIST header from the published content unless a file "+
+		"sets 'strip' itself")
+	fs.BoolVar(&opts.Dedent, "dedent", false, "remove the indentation shared by the lines of each region unless a file "+
+		"sets 'dedent' itself")
+	fs.BoolVar(&opts.Redact, "redact", false, "replace secrets, emails, private IPs and the configured redact patterns "+
+		"in the published content with placeholders unless a file sets 'redact' itself")
+	noWrite := fs.Bool("no-write", false, "do not write the id and url of new gists back into their files, e.g. "+
+		"for read-only checkouts")
+	useManifest := fs.Bool("manifest", false, "record pushed gists in .gist/manifest.json at the project root "+
+		"instead of in the files, used automatically once the manifest exists")
+	prune := fs.Bool("prune", false, "delete the gists of files recorded in the manifest that no longer exist")
+	fs.StringVar(&opts.Filename, "n", "", "publish the file under this name instead of its 'filename' key or base name")
+	dryRun := fs.Bool("dry-run", false, "print what would be sent to GitHub as JSON, without sending anything")
+	body := fs.Bool("body", false, "with -dry-run, include the exact request bodies")
+	yes := fs.Bool("yes", false, "create public gists without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no files to push")
+	}
+	if !*dryRun {
+		if err := requireLogin(); err != nil {
+			return err
+		}
+	}
+
+	if err := sameProject(fs.Args()); err != nil {
+		return err
+	}
+	m, err := loadManifest(fs.Arg(0), *useManifest)
+	if err != nil {
+		return err
+	}
+	if *prune && m == nil {
+		return fmt.Errorf("-prune needs a manifest, see -manifest")
+	}
+
+	scanner, err := loadSecretScanner(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts.Secrets = scanner
+	settings, err := loadSettings(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts.Public = settings.Public
+	keys, err := crypt.LoadKeys(settings)
+	if err != nil {
+		return err
+	}
+
+	//a dry run lists every file found, including those that are not gistable
+	results, err := scanPaths(fs.Args(), opts, *dryRun)
+	if err != nil {
+		return err
+	}
+	//a directory may hold another project, e.g. a submodule, whose settings apply to its files
+	paths := make([]string, 0, len(results)+1)
+	paths = append(paths, fs.Arg(0))
+	for _, result := range results {
+		paths = append(paths, result.Path)
+	}
+	if err := sameProject(paths); err != nil {
+		return err
+	}
+	if opts.Filename != "" && len(results) > 1 {
+		return fmt.Errorf("-n can only be used when pushing a single file, %d were found", len(results))
+	}
+
+	steps, err := planPush(results, m, scanner, settings, keys, *prune, *body)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		summary := map[string]int{}
+		for _, step := range steps {
+			summary[step.Action]++
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(pushPlan{Steps: steps, Summary: summary})
+	}
+	if !*yes && !confirmPublic(steps) {
+		return fmt.Errorf("nothing was pushed, set 'public: false' on the files to keep or pass -yes")
+	}
+
+	failed := 0
+	for _, step := range steps {
+		switch step.Action {
+		case actionSkip:
+			fmt.Fprintf(os.Stderr, "skipped %s: %s\n", step.Path, step.Reason)
+			printFindings("", step.Findings)
+			failed++
+		case actionUnchanged:
+			fmt.Printf("unchanged %s %s\n", step.Path, step.HTMLURL)
+		case actionMissing:
+			fmt.Printf("missing %s %s, push with -prune to delete its gist\n", step.Path, step.HTMLURL)
+		case actionDelete:
+			if err := deleteGist(step.GistID); err != nil {
+				fmt.Fprintf(os.Stderr, "could not delete the gist of %s -> %s\n", step.Path, err)
+				failed++
+				continue
+			}
+			m.Remove(step.Path)
+			fmt.Printf("deleted %s %s\n", step.Path, step.HTMLURL)
+		case actionCreate, actionUpdate:
+			printRedactions(step.Redactions)
+			printFindings("warning: ", step.Findings)
+			if err := runPushStep(step, m, *noWrite); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				failed++
+			}
+		}
+	}
+
+	if m != nil {
+		if err := m.Save(); err != nil {
+			return fmt.Errorf("could not save manifest -> %s", err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files were not pushed", failed, len(results))
+	}
+	return nil
+}
+
+//loadManifest returns the manifest of the project holding path. It returns nil if the project has no manifest,
+// unless create is set.
+func loadManifest(path string, create bool) (*manifest.Manifest, error) {
+	root, err := projectRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(manifest.Path(root)); os.IsNotExist(err) && !create {
+		return nil, nil
+	}
+	return manifest.Load(root)
+}
+
+//The actions of a pushStep
+const (
+	actionCreate    = "create"
+	actionUpdate    = "update"
+	actionUnchanged = "unchanged"
+	actionSkip      = "skip"
+	actionMissing   = "missing"
+	actionDelete    = "delete"
+)
+
+//pushPlan is what push prints with -dry-run
+type pushPlan struct {
+	Steps []*pushStep `json:"steps"`
+
+	//Summary counts the steps by action
+	Summary map[string]int `json:"summary"`
+}
+
+//pushStep is what push does for a single file: create or update its gist, skip it because it is unchanged or not
+// gistable, or, for files recorded in the manifest that no longer exist, report it missing or delete its gist.
+type pushStep struct {
+	//Path is the path of the file, or its manifest key for missing and deleted files
+	Path   string `json:"path"`
+	Action string `json:"action"`
+
+	//Reason explains why a file is skipped
+	Reason string `json:"reason,omitempty"`
+
+	GistID  string `json:"gist_id,omitempty"`
+	HTMLURL string `json:"html_url,omitempty"`
+
+	//Method and URL make up the request sent to the GitHub API
+	Method string `json:"method,omitempty"`
+	URL    string `json:"url,omitempty"`
+
+	//Gist describes the gist sent by create and update steps
+	Gist *plannedGist `json:"gist,omitempty"`
+
+	//Findings are the possible secrets in the gist. A public gist with findings is skipped, a private one is
+	// published with a warning.
+	Findings []secrets.Finding `json:"findings,omitempty"`
+
+	//Redactions are the substitutions made in the gist of a file that asked for redaction
+	Redactions []secrets.Redaction `json:"redactions,omitempty"`
+
+	result *utils.ScanResult
+	hash   string
+
+	//sent is the gist sent by create and update steps, encrypted if the file asks for it
+	sent *gists.GistFile
+}
+
+//plannedGist describes the gist sent by a pushStep
+type plannedGist struct {
+	Description string        `json:"description"`
+	Public      bool          `json:"public"`
+	Files       []plannedFile `json:"files"`
+
+	//Encrypt is how the files are encrypted, see gists.GistParser.GetEncrypt
+	Encrypt string `json:"encrypt,omitempty"`
+
+	//Size is the size in bytes of the content of every file
+	Size int `json:"size"`
+
+	//Body is the exact request body, it is only included when asked for
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+//plannedFile is a file of a plannedGist
+type plannedFile struct {
+	Filename string `json:"filename"`
+	Size     int    `json:"size"`
+}
+
+//planPush decides what push does with every scanned file, and with the files recorded in m, if any, that no longer
+// exist. Their gists are deleted if prune is set. Gists are checked for secrets with scanner, if set, public gists
+// are skipped if settings forbid them, and gists are encrypted with keys where their file asks for it. The request
+// body of create and update steps is included if body is set.
+func planPush(results []*utils.ScanResult, m *manifest.Manifest, scanner *secrets.Scanner, settings *config.Settings,
+	keys *crypt.Keys, prune, body bool) ([]*pushStep, error) {
+	steps := make([]*pushStep, 0, len(results))
+	for _, result := range results {
+		step := &pushStep{Path: result.Path, Redactions: result.Redactions, result: result}
+		steps = append(steps, step)
+		if result.Status != utils.ScanGistable {
+			step.Action, step.Reason = actionSkip, result.Error
+			continue
+		}
+
+		gist := result.Gist
+		if m != nil {
+			entry := m.Get(result.Path)
+			if gist.ID == "" && entry != nil {
+				gist.ID = entry.GistID
+			}
+			hash, err := manifest.Hash(gist)
+			if err != nil {
+				step.Action, step.Reason = actionSkip, err.Error()
+				continue
+			}
+			step.hash = hash
+			if entry.Unchanged(gist.ID, hash) {
+				step.Action, step.GistID, step.HTMLURL = actionUnchanged, gist.ID, entry.URL
+				continue
+			}
+		}
+
+		if err := checkVisibility(settings, gist); err != nil {
+			step.Action, step.Reason = actionSkip, err.Error()
+			continue
+		}
+		if scanner != nil {
+			step.Findings = findSecrets(scanner, result)
+			if len(step.Findings) > 0 && gist.Public {
+				step.Action, step.Reason = actionSkip, secretsError(step.Findings).Error()
+				continue
+			}
+		}
+
+		sent, err := sealGist(keys, result)
+		if err != nil {
+			step.Action, step.Reason = actionSkip, err.Error()
+			continue
+		}
+		step.sent = sent
+
+		step.Action, step.Method, step.URL = actionCreate, gists.EndpointGistCreateMethod,
+			gists.EndpointBase+gists.EndpointGistCreate
+		if gist.ID != "" {
+			step.Action, step.Method, step.URL = actionUpdate, gists.EndpointGistUpdateMethod, gists.GistURL(gist.ID)
+			step.GistID = gist.ID
+		}
+
+		planned := &plannedGist{Description: sent.Description, Public: sent.Public, Encrypt: result.Encrypt}
+		for _, f := range sent.Files {
+			planned.Files = append(planned.Files, plannedFile{Filename: f.Filename, Size: len(f.Content)})
+			planned.Size += len(f.Content)
+		}
+		if body {
+			data, err := json.Marshal(sent)
+			if err != nil {
+				step.Action, step.Reason = actionSkip, err.Error()
+				continue
+			}
+			planned.Body = data
+		}
+		step.Gist = planned
+	}
+
+	if m == nil {
+		return steps, nil
+	}
+	for _, key := range m.Missing() {
+		entry := m.Files[key]
+		step := &pushStep{Path: key, Action: actionMissing, GistID: entry.GistID, HTMLURL: entry.URL}
+		if prune {
+			step.Action, step.Method, step.URL = actionDelete, gists.EndpointGistDeleteMethod, gists.GistURL(entry.GistID)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+//confirmPublic lists the files about to be published as new public gists and asks whether to go ahead. It reports
+// true if there are none.
+func confirmPublic(steps []*pushStep) bool {
+	public := 0
+	for _, step := range steps {
+		if step.Action == actionCreate && step.Gist.Public {
+			fmt.Fprintf(os.Stderr, "public %s\n", step.Path)
+			public++
+		}
+	}
+	return public == 0 || confirm(fmt.Sprintf("Publish %d files as public gists?", public))
+}
+
+//runPushStep creates or updates the gist of a file, and records its id in m or, unless noWrite is set, in the file.
+func runPushStep(step *pushStep, m *manifest.Manifest, noWrite bool) error {
+	gist := step.result.Gist
+	pushed, err := pushGist(step.sent)
+	if err != nil {
+		return fmt.Errorf("could not push %s -> %s", step.Path, err)
+	}
+
+	switch {
+	case m != nil:
+		names := make([]string, 0, len(gist.Files))
+		for _, f := range gist.Files {
+			names = append(names, f.Filename)
+		}
+		err = m.Set(step.Path, &manifest.Entry{
+			GistID:   pushed.ID,
+			URL:      pushed.URL,
+			Files:    names,
+			Hash:     step.hash,
+			Revision: pushed.Revision,
+			SyncedAt: time.Now().UTC(),
+		})
+	case pushed.Created && !noWrite:
+		err = (&gists.GistParser{Filepath: step.Path}).WriteID(pushed.ID, pushed.URL)
+	}
+	if err != nil {
+		return fmt.Errorf("pushed %s to %s but could not record its id -> %s", step.Path, pushed.URL, err)
+	}
+
+	action := "updated"
+	if pushed.Created {
+		action = "created"
+	}
+	fmt.Printf("%s %s %s\n", action, step.Path, pushed.URL)
+	return nil
+}
+
+//deleteGist deletes the gist with the given id, a gist that no longer exists is not an error
+func deleteGist(id string) error {
+	resp, err := (&gists.GistFile{}).Delete(id)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+//statusError is returned for a request the GitHub API did not answer as expected
+type statusError struct {
+	code   int
+	status string
+}
+
+//Error returns the status of the response
+func (e *statusError) Error() string {
+	return e.status
+}
+
+//pushedGist describes the outcome of pushGist
+type pushedGist struct {
+	Created  bool
+	ID       string
+	URL      string
+	Revision string
+}
+
+//pushGist updates the gist identified by gist.ID, or creates one if it has no ID.
+func pushGist(gist *gists.GistFile) (*pushedGist, error) {
+	return pushGistContext(context.Background(), gist, nil)
+}
+
+//pushGistContext is pushGist with a context, see auth.WithToken. remote, if set, is the gist as it was retrieved,
+// whose visibility gists.GistFile.Update then checks the update against instead of fetching it again.
+func pushGistContext(ctx context.Context, gist, remote *gists.GistFile) (*pushedGist, error) {
+	var resp *http.Response
+	var err error
+	want := http.StatusOK
+	if gist.ID != "" {
+		current := &gists.GistFile{ID: gist.ID}
+		if remote != nil {
+			current.Public, current.URL = remote.Public, remote.URL
+		}
+		resp, err = current.UpdateContext(ctx, gist)
+	} else {
+		want = http.StatusCreated
+		resp, err = gist.CreateContext(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		ID      string `json:"id"`
+		HTMLURL string `json:"html_url"`
+		History []struct {
+			Version string `json:"version"`
+		} `json:"history"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != want || err != nil {
+		return nil, &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	pushed := &pushedGist{Created: want == http.StatusCreated, ID: body.ID, URL: body.HTMLURL}
+	if len(body.History) > 0 {
+		pushed.Revision = body.History[0].Version
+	}
+	return pushed, nil
+}
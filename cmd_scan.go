@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/utils"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+//scanCommand implements `gist scan`, which prints a ScanReport for each directory explaining why every candidate
+// file was or was not gistable.
+func scanCommand(args []string) error {
+	fs := newFlagSet("scan", "[flags] [dir...]")
+	opts := scanFlags(fs)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	status := fs.String("status", "", "only list files with this status: gistable, not-gistable, invalid or unreadable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	reports := make([]*utils.ScanReport, 0, len(dirs))
+	for _, dir := range dirs {
+		report, err := utils.ScanAllFilesInDir(dir, opts)
+		if err != nil {
+			return err
+		}
+		if *status != "" {
+			filtered := report.Results[:0]
+			for _, result := range report.Results {
+				if string(result.Status) == *status {
+					filtered = append(filtered, result)
+				}
+			}
+			report.Results = filtered
+		}
+		reports = append(reports, report)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tPATH\tDETAIL")
+	for _, report := range reports {
+		for _, result := range report.Results {
+			detail := result.Error
+			if result.Status == utils.ScanGistable {
+				names := make([]string, 0, len(result.Gist.Files))
+				for _, f := range result.Gist.Files {
+					names = append(names, f.Filename)
+				}
+				detail = fmt.Sprintf("files=%s public=%v description=%q", strings.Join(names, ","), result.Gist.Public,
+					result.Gist.Description)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", result.Status, result.Path, detail)
+			for _, d := range result.Diagnostics {
+				if d.Severity == gists.SeverityWarning {
+					fmt.Fprintf(w, "\t\t%s\n", d)
+				}
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		fmt.Printf("%s: %d gistable, %d not gistable, %d invalid, %d unreadable\n", report.Dir,
+			report.Count(utils.ScanGistable), report.Count(utils.ScanNotGistable), report.Count(utils.ScanInvalid),
+			report.Count(utils.ScanUnreadable))
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/crypt"
+	"github.com/martinomburajr/gist/diff"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/manifest"
+	"github.com/martinomburajr/gist/secrets"
+	"github.com/martinomburajr/gist/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//errConflict is returned by syncFile for files changed both locally and remotely
+var errConflict = errors.New("changed locally and remotely")
+
+//syncCommand implements `gist sync`, which brings the files recorded in the project's manifest and their gists back
+// in line. A file changed locally since it was last in sync is pushed, one whose gist changed is pulled. When both
+// changed the file is left alone, the remote version is saved next to it (see gists.RemoteSuffix) and a three-way
+// diff is printed.
+func syncCommand(args []string) error {
+	fs := newFlagSet("sync", "[flags] [path...]")
+	opts := &utils.ScanOptions{}
+	fs.BoolVar(&opts.Strip, "strip", false, "remove the GOGIST header from the published content unless a file "+
+		"sets 'strip' itself, as for push")
+	fs.BoolVar(&opts.Dedent, "dedent", false, "remove the indentation shared by the lines of each region unless a file "+
+		"sets 'dedent' itself, as for push")
+	fs.BoolVar(&opts.Redact, "redact", false, "replace secrets and the configured redact patterns in the published "+
+		"content with placeholders unless a file sets 'redact' itself, as for push")
+	prefer := fs.String("prefer", "", "resolve conflicts by keeping the 'local' or the 'remote' version")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *prefer != "" && *prefer != "local" && *prefer != "remote" {
+		fs.Usage()
+		return fmt.Errorf("-prefer must be local or remote, not %q", *prefer)
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	if err := requireLogin(); err != nil {
+		return err
+	}
+
+	if err := sameProject(paths); err != nil {
+		return err
+	}
+	m, err := loadManifest(paths[0], false)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("nothing to sync, push with -manifest or pull to record files in a manifest first")
+	}
+	keys, err := syncKeys(m, paths)
+	if err != nil {
+		return err
+	}
+	scanner, err := loadSecretScanner(paths[0])
+	if err != nil {
+		return err
+	}
+	opts.Secrets = scanner
+	settings, err := loadSettings(paths[0])
+	if err != nil {
+		return err
+	}
+	opts.Public = settings.Public
+	keyring, err := crypt.LoadKeys(settings)
+	if err != nil {
+		return err
+	}
+
+	failed, conflicts := 0, 0
+	for _, key := range keys {
+		err := syncFile(m, key, opts, scanner, settings, keyring, *prefer)
+		switch {
+		case err == errConflict:
+			conflicts++
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "could not sync %s -> %s\n", key, err)
+			failed++
+		}
+	}
+
+	if err := m.Save(); err != nil {
+		return fmt.Errorf("could not save manifest -> %s", err)
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d files have conflicts, merge them and sync with -prefer local, or discard the local "+
+			"changes with -prefer remote", conflicts)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files could not be synced", failed, len(keys))
+	}
+	return nil
+}
+
+//syncKeys returns the keys, in lexical order, of the files recorded in m that are one of paths or within them
+func syncKeys(m *manifest.Manifest, paths []string) ([]string, error) {
+	dirs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, abs)
+	}
+
+	keys := make([]string, 0, len(m.Files))
+	for key := range m.Files {
+		for _, dir := range dirs {
+			rel, err := filepath.Rel(dir, m.File(key))
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+//syncFile compares the file recorded under key with the state it was last synced in and with its gist, and pushes
+// or pulls it accordingly. prefer decides which side wins when both changed, without it errConflict is returned. The
+// gist is checked for secrets with scanner, and against the visibility policy of settings, before it is pushed. Gists
+// are encrypted and decrypted with keys. Files published redacted are never pulled, their placeholders would replace
+// the values they stand for.
+func syncFile(m *manifest.Manifest, key string, opts *utils.ScanOptions, scanner *secrets.Scanner,
+	settings *config.Settings, keys *crypt.Keys, prefer string) error {
+	path := m.File(key)
+	entry := m.Files[key]
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Printf("missing %s %s\n", key, entry.URL)
+		return nil
+	}
+
+	result := utils.ScanFile(path, opts)
+	if result.Status != utils.ScanGistable {
+		return fmt.Errorf("%s", result.Error)
+	}
+	local := result.Gist
+	if local.ID == "" {
+		local.ID = entry.GistID
+	}
+	localHash, err := manifest.Hash(local)
+	if err != nil {
+		return err
+	}
+
+	remote := &gists.GistFile{}
+	if _, err := remote.Retrieve(local.ID); err != nil {
+		return err
+	}
+	if _, err := keys.Decrypt(remote); err != nil {
+		return err
+	}
+	remoteHash, err := manifest.Hash(remote)
+	if err != nil {
+		return err
+	}
+
+	localChanged := localHash != entry.Hash
+	remoteChanged := remote.Revision != entry.Revision && remoteHash != entry.Hash
+	parser := &gists.GistParser{Filepath: path, Strip: opts.Strip, Dedent: opts.Dedent, Redact: opts.Redact,
+		Public: opts.Public}
+
+	switch {
+	case localHash == remoteHash:
+		if !localChanged && remote.Revision == entry.Revision {
+			fmt.Printf("unchanged %s %s\n", key, remote.URL)
+			return nil
+		}
+		fmt.Printf("in sync %s %s\n", key, remote.URL)
+		return recordSync(m, key, local, localHash, remote.Revision)
+
+	case localChanged && remoteChanged && prefer == "":
+		return syncConflict(parser, entry, keys, local, remote)
+
+	case localChanged && (!remoteChanged || prefer == "local"):
+		if err := checkVisibility(settings, local); err != nil {
+			return err
+		}
+		if err := checkSecrets(scanner, result); err != nil {
+			return err
+		}
+		sent, err := sealGist(keys, result)
+		if err != nil {
+			return err
+		}
+		pushed, err := pushGistContext(context.Background(), sent, remote)
+		if err != nil {
+			return err
+		}
+		printRedactions(result.Redactions)
+		fmt.Printf("pushed %s %s\n", key, pushed.URL)
+		return recordSync(m, key, local, localHash, pushed.Revision)
+
+	case remoteChanged:
+		if len(result.Redactions) > 0 {
+			return fmt.Errorf("its gist changed but it is published redacted, pulling would overwrite the redacted " +
+				"values, merge the changes by hand and sync with -prefer local")
+		}
+		if err := parser.Apply(remote); err != nil {
+			return err
+		}
+		pulled := utils.ScanFile(path, opts)
+		if pulled.Status != utils.ScanGistable {
+			return fmt.Errorf("pulled %s but it is no longer gistable -> %s", path, pulled.Error)
+		}
+		hash, err := manifest.Hash(pulled.Gist)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("pulled %s %s\n", key, remote.URL)
+		return recordSync(m, key, remote, hash, remote.Revision)
+	}
+
+	fmt.Printf("unchanged %s %s\n", key, remote.URL)
+	return nil
+}
+
+//recordSync records the file under key as in sync with gist, and removes any remote version left by a conflict.
+func recordSync(m *manifest.Manifest, key string, gist *gists.GistFile, hash, revision string) error {
+	names := make([]string, 0, len(gist.Files))
+	for _, f := range gist.Files {
+		names = append(names, f.Filename)
+	}
+	entry := m.Files[key]
+	m.Files[key] = &manifest.Entry{
+		GistID:   entry.GistID,
+		URL:      entry.URL,
+		Files:    names,
+		Hash:     hash,
+		Revision: revision,
+		SyncedAt: time.Now().UTC(),
+	}
+	if err := os.Remove(m.File(key) + gists.RemoteSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//syncConflict saves the remote version of a file changed both locally and remotely next to it, and prints a
+// three-way diff of every file of the gist against the revision it was last synced at, decrypted with keys. It returns
+// errConflict.
+func syncConflict(parser *gists.GistParser, entry *manifest.Entry, keys *crypt.Keys, local,
+	remote *gists.GistFile) error {
+	merged, err := parser.Merge(remote)
+	if err != nil {
+		return fmt.Errorf("%s, and the remote version cannot be saved -> %s", errConflict, err)
+	}
+	if err := ioutil.WriteFile(parser.Filepath+gists.RemoteSuffix, merged, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("conflict %s %s, the remote version is in %s\n", parser.Filepath, remote.URL,
+		parser.Filepath+gists.RemoteSuffix)
+
+	base := &gists.GistFile{}
+	if entry.Revision == "" {
+		fmt.Fprintf(os.Stderr, "no revision was recorded for %s, diffing against an empty base\n", parser.Filepath)
+	} else if _, err := base.RetrieveRevision(entry.GistID, entry.Revision); err != nil {
+		fmt.Fprintf(os.Stderr, "could not retrieve revision %s, diffing against an empty base -> %s\n",
+			entry.Revision, err)
+		base = &gists.GistFile{}
+	} else if _, err := keys.Decrypt(base); err != nil {
+		fmt.Fprintf(os.Stderr, "could not decrypt revision %s, diffing against an empty base -> %s\n",
+			entry.Revision, err)
+		base = &gists.GistFile{}
+	}
+
+	if local.Description != remote.Description {
+		fmt.Printf("@@ description: local %q base %q remote %q @@\n", local.Description, base.Description,
+			remote.Description)
+	}
+	if local.Public != remote.Public {
+		fmt.Printf("@@ public: local %t remote %t @@\n", local.Public, remote.Public)
+	}
+
+	contents := func(g *gists.GistFile) map[string]string {
+		files := map[string]string{}
+		for _, f := range g.Files {
+			files[f.Filename] = f.Content
+		}
+		return files
+	}
+	baseFiles, localFiles, remoteFiles := contents(base), contents(local), contents(remote)
+	names := make([]string, 0, len(localFiles)+len(remoteFiles))
+	for name := range localFiles {
+		names = append(names, name)
+	}
+	for name := range remoteFiles {
+		if _, ok := localFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		chunks := diff.Diff3(diff.Lines(baseFiles[name]), diff.Lines(localFiles[name]), diff.Lines(remoteFiles[name]))
+		if err := diff.Write(os.Stdout, name, chunks); err != nil {
+			return err
+		}
+	}
+	return errConflict
+}
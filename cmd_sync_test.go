@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/manifest"
+	"github.com/martinomburajr/gist/secrets"
+	"github.com/martinomburajr/gist/utils"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//serveSync has auth.Session.Client answer GETs with remote, the gist abc at revision, and count the updates it is
+// sent, which GitHub accepts as revision v3. It returns the function restoring the client.
+func serveSync(remote *gists.GistFile, revision string, updates *int) func() {
+	client := auth.Session.Client
+	auth.Session.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		files := map[string]interface{}{}
+		for _, f := range remote.Files {
+			files[f.Filename] = map[string]string{"content": f.Content}
+		}
+		gist := map[string]interface{}{
+			"id":          "abc",
+			"html_url":    "https://gist.github.com/abc",
+			"description": remote.Description,
+			"public":      remote.Public,
+			"files":       files,
+			"history":     []map[string]string{{"version": revision}},
+		}
+		if r.Method == http.MethodPatch {
+			*updates++
+			gist["history"] = []map[string]string{{"version": "v3"}}
+		}
+		recorder := httptest.NewRecorder()
+		json.NewEncoder(recorder).Encode(gist)
+		return recorder.Result(), nil
+	})}
+	return func() { auth.Session.Client = client }
+}
+
+func Test_syncFile(t *testing.T) {
+	scanner, err := secrets.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const synced = "// start gist\n// description: main\n// end gist\npackage main\n"
+
+	tests := []struct {
+		name          string
+		localChanged  bool
+		remoteChanged bool
+		prefer        string
+		want          string
+	}{
+		{"unchanged", false, false, "", "unchanged"},
+		{"unchanged prefer local", false, false, "local", "unchanged"},
+		{"unchanged prefer remote", false, false, "remote", "unchanged"},
+		{"local changed", true, false, "", "pushed"},
+		{"local changed prefer local", true, false, "local", "pushed"},
+		{"local changed prefer remote", true, false, "remote", "pushed"},
+		{"remote changed", false, true, "", "pulled"},
+		{"remote changed prefer local", false, true, "local", "pulled"},
+		{"remote changed prefer remote", false, true, "remote", "pulled"},
+		{"both changed", true, true, "", "conflict"},
+		{"both changed prefer local", true, true, "local", "pushed"},
+		{"both changed prefer remote", true, true, "remote", "pulled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "main.go")
+			if err := ioutil.WriteFile(path, []byte(synced), 0600); err != nil {
+				t.Fatal(err)
+			}
+			base := utils.ScanFile(path, nil).Gist
+			hash, err := manifest.Hash(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := manifest.Load(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Set(path, &manifest.Entry{GistID: "abc", URL: "https://gist.github.com/abc", Files: []string{"main.go"},
+				Hash: hash, Revision: "v1"})
+
+			local := synced
+			if tt.localChanged {
+				local += "\nfunc local() {}\n"
+				if err := ioutil.WriteFile(path, []byte(local), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			remote := &gists.GistFile{Description: base.Description, Public: base.Public,
+				Files: []gists.GistFileBody{{Filename: "main.go", Content: base.Files[0].Content}}}
+			revision := "v1"
+			if tt.remoteChanged {
+				remote.Files[0].Content += "\nfunc remote() {}\n"
+				revision = "v2"
+			}
+			updates := 0
+			defer serveSync(remote, revision, &updates)()
+
+			key, _ := m.Key(path)
+			err = syncFile(m, key, &utils.ScanOptions{}, scanner, nil, nil, tt.prefer)
+			if (err == errConflict) != (tt.want == "conflict") || (err != nil && err != errConflict) {
+				t.Fatalf("syncFile() error = %v, want %s", err, tt.want)
+			}
+
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, statErr := os.Stat(path + gists.RemoteSuffix)
+			entry := m.Get(path)
+			wantRevision, wantContent := "v1", local
+			switch tt.want {
+			case "pushed":
+				wantRevision = "v3"
+			case "pulled":
+				wantRevision, wantContent = "v2", synced+"\nfunc remote() {}\n"
+			}
+			if pushed := updates > 0; pushed != (tt.want == "pushed") {
+				t.Errorf("syncFile() sent %d updates, want %s", updates, tt.want)
+			}
+			if string(content) != wantContent {
+				t.Errorf("syncFile() left %s as %q, want %q", path, content, wantContent)
+			}
+			if entry.Revision != wantRevision {
+				t.Errorf("syncFile() recorded revision %s, want %s", entry.Revision, wantRevision)
+			}
+			if tt.want == "conflict" != (statErr == nil) {
+				t.Errorf("syncFile() saved the remote version = %v, want %v", statErr == nil, tt.want == "conflict")
+			}
+			if tt.want != "conflict" {
+				scanned := utils.ScanFile(path, nil)
+				if hash, _ := manifest.Hash(scanned.Gist); entry.Hash != hash {
+					t.Errorf("syncFile() recorded hash %s, want that of %s", entry.Hash, strings.TrimSpace(string(content)))
+				}
+			}
+		})
+	}
+}
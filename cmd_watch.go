@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/crypt"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/manifest"
+	"github.com/martinomburajr/gist/secrets"
+	"github.com/martinomburajr/gist/utils"
+	"github.com/martinomburajr/gist/watch"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+//maxRetryDelay caps the delay between attempts to publish a file after the GitHub API failed
+const maxRetryDelay = 5 * time.Minute
+
+//watchCommand implements `gist watch`, which republishes the gistable files within a directory as they change. Only
+// files whose gist exists, i.e. that have an 'id' in their header or an entry in the project's manifest, are
+// published, and only once their content or metadata changed. Failed publishes are retried with a growing delay.
+func watchCommand(args []string) error {
+	fs := newFlagSet("watch", "[flags] [dir]")
+	opts := scanFlags(fs)
+	fs.BoolVar(&opts.Strip, "strip", false, "remove the GOGIST header from the published content unless a file "+
+		"sets 'strip' itself")
+	fs.BoolVar(&opts.Dedent, "dedent", false, "remove the indentation shared by the lines of each region unless a file "+
+		"sets 'dedent' itself")
+	fs.BoolVar(&opts.Redact, "redact", false, "replace secrets and the configured redact patterns in the published "+
+		"content with placeholders unless a file sets 'redact' itself")
+	interval := fs.Duration("interval", time.Second, "how often to poll for changes")
+	debounce := fs.Duration("debounce", 500*time.Millisecond, "how long a file must be left alone after a change "+
+		"before it is published")
+	poll := fs.Bool("poll", false, "poll for changes even where the operating system can report them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("watch takes a single directory")
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+	if err := requireLogin(); err != nil {
+		return err
+	}
+
+	m, err := loadManifest(dir, false)
+	if err != nil {
+		return err
+	}
+	scanner, err := loadSecretScanner(dir)
+	if err != nil {
+		return err
+	}
+	opts.Secrets = scanner
+	settings, err := loadSettings(dir)
+	if err != nil {
+		return err
+	}
+	opts.Public = settings.Public
+	keys, err := crypt.LoadKeys(settings)
+	if err != nil {
+		return err
+	}
+	p := &publisher{opts: opts, manifest: m, scanner: scanner, settings: settings, keys: keys,
+		hashes: map[string]string{}, retries: map[string]*retry{}}
+	files := func() ([]string, error) {
+		return utils.GetAllFilesInDir(dir, opts)
+	}
+	if err := p.start(files); err != nil {
+		return err
+	}
+
+	w, err := watch.New(dir, watch.Options{Interval: *interval, Debounce: *debounce, Poll: *poll, Files: files})
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	log.Printf("watching %d gistable files in %s", len(p.hashes), dir)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case changed := <-w.Changes:
+			watched, err := files()
+			if err != nil {
+				log.Printf("could not list %s -> %s", dir, err)
+				continue
+			}
+			listed := make(map[string]bool, len(watched))
+			for _, path := range watched {
+				listed[path] = true
+			}
+			for _, path := range changed {
+				if listed[path] {
+					p.publish(path)
+				}
+			}
+		case err := <-w.Errors:
+			log.Printf("could not watch %s -> %s", dir, err)
+		case now := <-ticker.C:
+			for path, r := range p.retries {
+				if !now.Before(r.at) {
+					p.publish(path)
+				}
+			}
+		case <-stop:
+			log.Printf("stopped watching %s", dir)
+			return nil
+		}
+	}
+}
+
+//retry records a file whose publish failed
+type retry struct {
+	attempts int
+	at       time.Time
+}
+
+//publisher publishes files as they change, remembering the hash of what was last published for each file
+type publisher struct {
+	opts     *utils.ScanOptions
+	manifest *manifest.Manifest
+	scanner  *secrets.Scanner
+	settings *config.Settings
+	keys     *crypt.Keys
+	hashes   map[string]string
+	retries  map[string]*retry
+}
+
+//start records the hash every gistable file was last published with, taken from the manifest if the file is in it
+// and from its current content otherwise, so that only later changes are published.
+func (p *publisher) start(files func() ([]string, error)) error {
+	paths, err := files()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if entry := p.entry(path); entry != nil {
+			p.hashes[path] = entry.Hash
+			continue
+		}
+		result := utils.ScanFile(path, p.opts)
+		if result.Status != utils.ScanGistable {
+			continue
+		}
+		if p.hashes[path], err = manifest.Hash(result.Gist); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//entry returns the manifest entry of the file at path, or nil if there is none
+func (p *publisher) entry(path string) *manifest.Entry {
+	if p.manifest == nil {
+		return nil
+	}
+	return p.manifest.Get(path)
+}
+
+//publish updates the gist of the file at path if its content or metadata changed since it was last published. A
+// failed update is scheduled to be retried.
+func (p *publisher) publish(path string) {
+	result := utils.ScanFile(path, p.opts)
+	switch result.Status {
+	case utils.ScanGistable:
+	case utils.ScanNotGistable, utils.ScanUnreadable:
+		delete(p.retries, path)
+		return
+	default:
+		delete(p.retries, path)
+		log.Printf("skipped %s: %s", path, result.Error)
+		return
+	}
+
+	gist := result.Gist
+	entry := p.entry(path)
+	if gist.ID == "" && entry != nil {
+		gist.ID = entry.GistID
+	}
+	hash, err := manifest.Hash(gist)
+	if err != nil {
+		log.Printf("skipped %s: %s", path, err)
+		return
+	}
+	if hash == p.hashes[path] {
+		delete(p.retries, path)
+		return
+	}
+	if gist.ID == "" {
+		log.Printf("skipped %s: it has no gist yet, push it first", path)
+		p.hashes[path] = hash
+		return
+	}
+
+	if err := checkVisibility(p.settings, gist); err != nil {
+		delete(p.retries, path)
+		log.Printf("skipped %s: %s", path, err)
+		return
+	}
+	if err := checkSecrets(p.scanner, result); err != nil {
+		delete(p.retries, path)
+		log.Printf("skipped %s: %s", path, err)
+		return
+	}
+
+	sent, err := sealGist(p.keys, result)
+	if err != nil {
+		delete(p.retries, path)
+		log.Printf("skipped %s: %s", path, err)
+		return
+	}
+	pushed, err := pushGist(sent)
+	if err == gists.ErrMadePublic || err == gists.ErrMadeSecret {
+		//retrying cannot help, the file has to change first
+		delete(p.retries, path)
+		p.hashes[path] = hash
+		log.Printf("skipped %s: %s", path, err)
+		return
+	}
+	if err != nil {
+		r := p.retries[path]
+		if r == nil {
+			r = &retry{}
+			p.retries[path] = r
+		}
+		delay := time.Second << uint(r.attempts)
+		if delay > maxRetryDelay || delay <= 0 {
+			delay = maxRetryDelay
+		}
+		r.attempts++
+		r.at = time.Now().Add(delay)
+		log.Printf("could not publish %s, retrying in %s -> %s", path, delay, err)
+		return
+	}
+	delete(p.retries, path)
+	p.hashes[path] = hash
+	for _, r := range result.Redactions {
+		log.Printf("redacted %s", r)
+	}
+
+	if entry != nil {
+		names := make([]string, 0, len(gist.Files))
+		for _, f := range gist.Files {
+			names = append(names, f.Filename)
+		}
+		p.manifest.Set(path, &manifest.Entry{
+			GistID:   pushed.ID,
+			URL:      pushed.URL,
+			Files:    names,
+			Hash:     hash,
+			Revision: pushed.Revision,
+			SyncedAt: time.Now().UTC(),
+		})
+		if err := p.manifest.Save(); err != nil {
+			log.Printf("could not save manifest -> %s", err)
+		}
+	}
+	log.Printf("published %s %s", path, pushed.URL)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/manifest"
+	"github.com/martinomburajr/gist/secrets"
+	"github.com/martinomburajr/gist/utils"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//serveUpdates has auth.Session.Client answer GETs with the secret gist abc and count the updates it is sent, which
+// fail with 502 Bad Gateway while fail is set. It returns the function restoring the client.
+func serveUpdates(fail *bool, updates *int) func() {
+	client := auth.Session.Client
+	auth.Session.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		if r.Method == http.MethodPatch {
+			*updates++
+			if *fail {
+				recorder.WriteHeader(http.StatusBadGateway)
+				return recorder.Result(), nil
+			}
+		}
+		json.NewEncoder(recorder).Encode(map[string]interface{}{
+			"id":       "abc",
+			"html_url": "https://gist.github.com/abc",
+			"public":   false,
+			"history":  []map[string]string{{"version": "v2"}},
+		})
+		return recorder.Result(), nil
+	})}
+	return func() { auth.Session.Client = client }
+}
+
+func Test_publisher_publish(t *testing.T) {
+	scanner, err := secrets.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		header       string
+		inManifest   bool
+		published    bool
+		attempts     int
+		fail         bool
+		wantUpdates  int
+		wantAttempts int
+		wantDelay    time.Duration
+		wantRecorded bool
+	}{
+		{"unchanged", "// id: abc\n", false, true, 0, false, 0, 0, 0, true},
+		{"unchanged clears retries", "// id: abc\n", false, true, 2, false, 0, 0, 0, true},
+		{"no gist yet", "", false, false, 0, false, 0, 0, 0, true},
+		{"first failure", "// id: abc\n", false, false, 0, true, 1, 1, time.Second, false},
+		{"third failure", "// id: abc\n", false, false, 2, true, 1, 3, 4 * time.Second, false},
+		{"capped", "// id: abc\n", false, false, 9, true, 1, 10, maxRetryDelay, false},
+		{"capped on overflow", "// id: abc\n", false, false, 70, true, 1, 71, maxRetryDelay, false},
+		{"success clears retries", "// id: abc\n", false, false, 3, false, 1, 0, 0, true},
+		{"manifest", "", true, false, 0, false, 1, 0, 0, true},
+		{"manifest failure", "", true, false, 0, true, 1, 1, time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fail, updates := tt.fail, 0
+			defer serveUpdates(&fail, &updates)()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "main.go")
+			content := "// start gist\n// description: main\n" + tt.header + "// end gist\npackage main\n"
+			if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			m, err := manifest.Load(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.inManifest {
+				m.Set(path, &manifest.Entry{GistID: "abc", Hash: "sha256:old", Revision: "v1"})
+			}
+			result := utils.ScanFile(path, nil)
+			hash, err := manifest.Hash(result.Gist)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := &publisher{manifest: m, scanner: scanner, hashes: map[string]string{},
+				retries: map[string]*retry{}}
+			if tt.published {
+				p.hashes[path] = hash
+			}
+			if tt.attempts > 0 {
+				p.retries[path] = &retry{attempts: tt.attempts}
+			}
+			before := time.Now()
+			p.publish(path)
+			after := time.Now()
+
+			if updates != tt.wantUpdates {
+				t.Errorf("publish() sent %d updates, want %d", updates, tt.wantUpdates)
+			}
+			r := p.retries[path]
+			switch {
+			case tt.wantAttempts == 0 && r != nil:
+				t.Errorf("publish() left a retry %+v, want none", r)
+			case tt.wantAttempts > 0 && r == nil:
+				t.Errorf("publish() scheduled no retry, want attempt %d", tt.wantAttempts)
+			case tt.wantAttempts > 0:
+				if r.attempts != tt.wantAttempts {
+					t.Errorf("publish() retry attempts = %d, want %d", r.attempts, tt.wantAttempts)
+				}
+				if r.at.Before(before.Add(tt.wantDelay)) || r.at.After(after.Add(tt.wantDelay)) {
+					t.Errorf("publish() retries in %s, want %s", r.at.Sub(before), tt.wantDelay)
+				}
+			}
+			if recorded := p.hashes[path] == hash; recorded != tt.wantRecorded {
+				t.Errorf("publish() recorded the hash = %v, want %v", recorded, tt.wantRecorded)
+			}
+
+			if tt.inManifest {
+				entry := m.Get(path)
+				published := tt.wantUpdates > 0 && !tt.fail
+				if updated := entry.Hash == hash && entry.Revision == "v2"; updated != published {
+					t.Errorf("publish() left the manifest entry %+v, want it updated = %v", entry, published)
+				}
+				if published && (entry.GistID != "abc" || entry.URL != "https://gist.github.com/abc" ||
+					len(entry.Files) != 1 || entry.Files[0] != "main.go") {
+					t.Errorf("publish() recorded %+v, want gist abc with main.go", entry)
+				}
+				if saved, err := manifest.Load(dir); err != nil || (saved.Get(path) != nil) != published {
+					t.Errorf("publish() saved the manifest = %v, %v, want %v", saved.Get(path) != nil, err,
+						published)
+				}
+			}
+
+			if !tt.fail {
+				return
+			}
+			fail = false
+			p.publish(path)
+			if updates != tt.wantUpdates+1 || p.retries[path] != nil || p.hashes[path] != hash {
+				t.Errorf("publish() once GitHub is back sent %d updates, left retry %+v, want it published",
+					updates-tt.wantUpdates, p.retries[path])
+			}
+			if entry := m.Get(path); tt.inManifest && (entry.Hash != hash || entry.Revision != "v2") {
+				t.Errorf("publish() once GitHub is back left the manifest entry %+v", entry)
+			}
+		})
+	}
+}
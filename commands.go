@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/crypt"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/manifest"
+	"github.com/martinomburajr/gist/secrets"
+	"github.com/martinomburajr/gist/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+//command is a subcommand of the gist binary, invoked as `gist <name> [flags] [args]`
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+//commands lists every subcommand in the order they are shown by usage.
+var commands = []*command{
+	{name: "push", summary: "create a gist for every gistable file", run: pushCommand},
+	{name: "pull", summary: "download gists into a local directory", run: pullCommand},
+	{name: "sync", summary: "push or pull every file recorded in the manifest, whichever changed", run: syncCommand},
+	{name: "watch", summary: "republish gists as their files change", run: watchCommand},
+	{name: "scan", summary: "report which files in a directory are gistable and why", run: scanCommand},
+	{name: "cache", summary: "inspect or clear the local response cache", run: cacheCommand},
+}
+
+//runCommand runs the subcommand called name with the remaining command line arguments.
+func runCommand(name string, args []string) error {
+	for _, c := range commands {
+		if c.name == name {
+			return c.run(args)
+		}
+	}
+	usage()
+	return fmt.Errorf("unknown command %q", name)
+}
+
+//usage prints the top level help text listing every subcommand.
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: gist [command] [flags] [args]\n\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "Without a command gist starts the login server on port %d.\n\n", config.PORT)
+	w := tabwriter.NewWriter(flag.CommandLine.Output(), 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t%s\t%s\n", c.name, c.summary)
+	}
+	w.Flush()
+	flag.PrintDefaults()
+}
+
+//newFlagSet returns a FlagSet for the subcommand called name that prints its usage line and defaults to stderr.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet("gist "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gist %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+//stringsFlag is a flag that may be given several times, collecting every value
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+//scanFlags registers the flags shared by every command that scans directories and returns the ScanOptions they
+// describe once fs has been parsed.
+func scanFlags(fs *flag.FlagSet) *utils.ScanOptions {
+	opts := &utils.ScanOptions{}
+	fs.Var((*stringsFlag)(&opts.Include), "include", "only scan files matching this glob, may be repeated")
+	fs.Var((*stringsFlag)(&opts.Exclude), "exclude", "skip files and directories matching this glob, may be repeated")
+	fs.IntVar(&opts.MaxDepth, "depth", 0, "maximum directory depth to scan, 0 for no limit")
+	fs.BoolVar(&opts.FollowSymlinks, "follow", false, "follow symlinked files and directories")
+	fs.BoolVar(&opts.NoIgnoreFiles, "no-ignore", false, "do not honour .gitignore and .gistignore files")
+	return opts
+}
+
+//scanPaths scans every path, which may be a file or a directory, and returns the results in order. Files named
+// explicitly are always included in the results, whereas only the gistable files found in directories are unless all
+// is set.
+func scanPaths(paths []string, opts *utils.ScanOptions, all bool) ([]*utils.ScanResult, error) {
+	results := make([]*utils.ScanResult, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			results = append(results, utils.ScanFile(path, opts))
+			continue
+		}
+
+		report, err := utils.ScanAllFilesInDir(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range report.Results {
+			if all || result.Status == utils.ScanGistable {
+				results = append(results, result)
+			}
+		}
+	}
+	return results, nil
+}
+
+//stdin reads answers to the questions asked by confirm
+var stdin = bufio.NewReader(os.Stdin)
+
+//confirm asks question on the terminal and reports whether it was answered yes. Without a terminal to ask on the
+// answer is always no.
+func confirm(question string) bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+//requireLogin returns an error if there is no AccessToken to talk to GitHub with
+func requireLogin() error {
+	if auth.Session.AccessToken == "" {
+		return fmt.Errorf("not logged in, set %s to a token with the gist scope or run gist without a command to "+
+			"log in", auth.TokenEnv)
+	}
+	return nil
+}
+
+//projectRoot returns the root of the project holding path, a file or a directory, see manifest.FindRoot
+func projectRoot(path string) (string, error) {
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	return manifest.FindRoot(dir)
+}
+
+//projectOf returns the root of the project holding path, see projectRoot, or "" if path is in no project, i.e. no
+// manifest or .git directory marks one above it
+func projectOf(path string) (string, error) {
+	root, err := projectRoot(path)
+	if err != nil {
+		return "", err
+	}
+	for _, marker := range []string{manifest.Path(root), filepath.Join(root, ".git")} {
+		if _, err := os.Stat(marker); err == nil {
+			return root, nil
+		}
+	}
+	return "", nil
+}
+
+//sameProject returns an error unless every one of paths is in the project of the first, see projectOf. Commands apply
+// the settings, secret rules and manifest of a single project, those of any other project would be bypassed.
+func sameProject(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	first, err := projectOf(paths[0])
+	if err != nil {
+		return err
+	}
+	for _, path := range paths[1:] {
+		root, err := projectOf(path)
+		if err != nil {
+			return err
+		}
+		if root != first {
+			return fmt.Errorf("%s and %s are in different projects, whose settings may differ, run gist once for "+
+				"each project", paths[0], path)
+		}
+	}
+	return nil
+}
+
+//loadSecretScanner returns a secrets.Scanner with the rules, redact patterns and allowlist of the user's
+// secrets.ConfigFile and of the one of the project holding path.
+func loadSecretScanner(path string) (*secrets.Scanner, error) {
+	root, err := projectRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := secrets.LoadConfig(filepath.Join(config.Dir(), secrets.ConfigFile),
+		filepath.Join(root, config.DirName, secrets.ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	return secrets.New(cfg)
+}
+
+//loadSettings returns the settings of the user's config.SettingsFile combined with the one of the project holding
+// path, see config.LoadSettings
+func loadSettings(path string) (*config.Settings, error) {
+	root, err := projectRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadSettings(filepath.Join(config.Dir(), config.SettingsFile),
+		filepath.Join(root, config.DirName, config.SettingsFile))
+}
+
+//checkVisibility returns an error if gist is public while settings forbid public gists
+func checkVisibility(settings *config.Settings, gist *gists.GistFile) error {
+	if gist.Public && settings != nil && settings.ForbidPublic {
+		return fmt.Errorf("public gists are forbidden by forbid_public in %s, set 'public: false'",
+			config.SettingsFile)
+	}
+	return nil
+}
+
+//sealGist returns the gist to publish for a gistable file, encrypted if the file asks for it
+func sealGist(keys *crypt.Keys, result *utils.ScanResult) (*gists.GistFile, error) {
+	if result.Encrypt == "" {
+		return result.Gist, nil
+	}
+	return keys.Encrypt(result.Gist, result.Encrypt)
+}
+
+//findSecrets returns the possible secrets in the gist of a gistable file. Only the description of an encrypted gist
+// is scanned, as it is the only part sent in plaintext.
+func findSecrets(scanner *secrets.Scanner, result *utils.ScanResult) []secrets.Finding {
+	if result.Encrypt != "" {
+		findings := scanner.Scan(result.Path, result.Gist.Description)
+		for i := range findings {
+			findings[i].Line = 0
+		}
+		return findings
+	}
+	//without the source, findings are reported within the files of the gist
+	source, _ := ioutil.ReadFile(result.Path)
+	return scanner.ScanGist(result.Path, source, result.Gist)
+}
+
+//secretsError returns the error refusing to publish a public gist with possible secrets
+func secretsError(findings []secrets.Finding) error {
+	return fmt.Errorf("refusing to publish a public gist with %d possible secrets, make it private, remove them or "+
+		"allow them with '%s'", len(findings), secrets.AllowPragma)
+}
+
+//checkSecrets scans the gist of a gistable file before it is published. Public gists with possible secrets are
+// refused with an error, for private gists the findings are printed as warnings. Only the description of encrypted
+// gists is scanned, see findSecrets.
+func checkSecrets(scanner *secrets.Scanner, result *utils.ScanResult) error {
+	findings := findSecrets(scanner, result)
+	if len(findings) == 0 {
+		return nil
+	}
+	if result.Gist.Public {
+		printFindings("", findings)
+		return secretsError(findings)
+	}
+	printFindings("warning: ", findings)
+	return nil
+}
+
+//printFindings prints every finding on its own line to stderr, after prefix
+func printFindings(prefix string, findings []secrets.Finding) {
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "%spossible secret %s\n", prefix, f)
+	}
+}
+
+//printRedactions prints every substitution made in a published gist on its own line to stderr
+func printRedactions(redactions []secrets.Redaction) {
+	for _, r := range redactions {
+		fmt.Fprintf(os.Stderr, "redacted %s\n", r)
+	}
+}
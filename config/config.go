@@ -1,6 +1,117 @@
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
 const (
 	//PORT is the port at which the server can be accessed
 	PORT = 8089
-)
\ No newline at end of file
+
+	//DirName is the name of the directory, relative to the user's home directory, where gist keeps its local state
+	DirName = ".gist"
+
+	//CacheMaxBytes is the upper bound on the size of the on-disk response cache. Least recently used entries are
+	// evicted once it is exceeded.
+	CacheMaxBytes = 16 << 20
+)
+
+//Dir returns the directory gist keeps its local state in. It falls back to a relative DirName if the home directory
+// cannot be determined.
+func Dir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return DirName
+	}
+	return filepath.Join(home, DirName)
+}
+
+//CacheDir returns the directory that holds cached GitHub API responses
+func CacheDir() string {
+	return filepath.Join(Dir(), "cache")
+}
+
+//SessionsDir returns the directory the login server keeps the sessions of logged in browsers in
+func SessionsDir() string {
+	return filepath.Join(Dir(), "sessions")
+}
+
+//UIDir returns the directory whose files override the templates and static files of the browser UI
+func UIDir() string {
+	return filepath.Join(Dir(), "ui")
+}
+
+//TokenFile is the name of the file, within the DirName directory of the user, holding the AccessToken saved by
+// logging in
+const TokenFile = "token"
+
+//SettingsFile is the name of the file, within the DirName directory of the user or of a project, holding Settings
+const SettingsFile = "config.json"
+
+//IdentityFile is the name of the file, within the DirName directory of the user, holding the age identities
+// encrypted gists are decrypted with unless Settings.Identity says otherwise
+const IdentityFile = "identity.txt"
+
+//Settings are the preferences of the user and of a project
+//
+//	{
+//	  "public": false,
+//	  "forbid_public": true,
+//	  "recipients": {"default": ["age1..."], "team": ["age1...", "age1..."]},
+//	  "identity": "/home/me/.config/age/keys.txt"
+//	}
+type Settings struct {
+	//Public is the visibility of the gists of files that do not set 'public' themselves, gists are secret unless it
+	// is set
+	Public bool `json:"public"`
+
+	//ForbidPublic refuses to publish public gists at all, e.g. as the policy of an organisation
+	ForbidPublic bool `json:"forbid_public"`
+
+	//Recipients maps the name of an encryption profile to the age public keys of those who can read the gists
+	// encrypted for it, see gists.GistParser.GetEncrypt
+	Recipients map[string][]string `json:"recipients"`
+
+	//Identity is the path of the file holding the age identities encrypted gists are decrypted with, IdentityFile
+	// within Dir by default
+	Identity string `json:"identity"`
+}
+
+//LoadSettings reads and combines the settings at paths, paths that do not exist are skipped. A later path overrides
+// the Public, Identity and profiles of an earlier one, whereas ForbidPublic holds once any of them sets it.
+func LoadSettings(paths ...string) (*Settings, error) {
+	settings := &Settings{Recipients: map[string][]string{}, Identity: filepath.Join(Dir(), IdentityFile)}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read settings -> %s", err)
+		}
+		var s struct {
+			Public       *bool               `json:"public"`
+			ForbidPublic bool                `json:"forbid_public"`
+			Recipients   map[string][]string `json:"recipients"`
+			Identity     string              `json:"identity"`
+		}
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("could not parse settings %s -> %s", path, err)
+		}
+		if s.Public != nil {
+			settings.Public = *s.Public
+		}
+		settings.ForbidPublic = settings.ForbidPublic || s.ForbidPublic
+		for profile, recipients := range s.Recipients {
+			settings.Recipients[profile] = recipients
+		}
+		if s.Identity != "" {
+			settings.Identity = s.Identity
+		}
+	}
+	return settings, nil
+}
@@ -0,0 +1,156 @@
+package crypt
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"fmt"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/gists"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+//Suffix is appended to the name of every file of an encrypted gist
+const Suffix = ".age"
+
+//PassphraseEnv is the environment variable holding the passphrase gists are encrypted and decrypted with, see
+// gists.EncryptPassphrase
+const PassphraseEnv = "GIST_PASSPHRASE"
+
+//Keys encrypt gists to the recipients of a profile or with a passphrase, and decrypt them with the user's identities
+type Keys struct {
+	//Profiles maps the name of an encryption profile to its recipients
+	Profiles map[string][]age.Recipient
+
+	//Identities decrypt gists, the passphrase is one of them if it is set
+	Identities []age.Identity
+
+	passphrase string
+}
+
+//LoadKeys parses the recipients of the profiles of settings and the identities in the settings.Identity file, which
+// need not exist. The passphrase is read from PassphraseEnv.
+func LoadKeys(settings *config.Settings) (*Keys, error) {
+	k := &Keys{Profiles: map[string][]age.Recipient{}, passphrase: os.Getenv(PassphraseEnv)}
+	for profile, recipients := range settings.Recipients {
+		parsed, err := age.ParseRecipients(strings.NewReader(strings.Join(recipients, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipients for encryption profile %q -> %s", profile, err)
+		}
+		k.Profiles[profile] = parsed
+	}
+
+	data, err := ioutil.ReadFile(settings.Identity)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read identities -> %s", err)
+	}
+	if err == nil {
+		if k.Identities, err = age.ParseIdentities(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("could not parse identities %s -> %s", settings.Identity, err)
+		}
+	}
+	if k.passphrase != "" {
+		identity, err := age.NewScryptIdentity(k.passphrase)
+		if err != nil {
+			return nil, err
+		}
+		k.Identities = append(k.Identities, identity)
+	}
+	return k, nil
+}
+
+//recipients returns the recipients profile encrypts to, see gists.GistParser.GetEncrypt
+func (k *Keys) recipients(profile string) ([]age.Recipient, error) {
+	if profile == gists.EncryptPassphrase {
+		if k.passphrase == "" {
+			return nil, fmt.Errorf("encrypting with a passphrase needs %s to be set", PassphraseEnv)
+		}
+		recipient, err := age.NewScryptRecipient(k.passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []age.Recipient{recipient}, nil
+	}
+	recipients := k.Profiles[profile]
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("encryption profile %q has no recipients, add them to %s", profile,
+			config.SettingsFile)
+	}
+	return recipients, nil
+}
+
+//Encrypt returns a copy of gist whose files are encrypted for profile and armored, and named with Suffix. The
+// description is not encrypted.
+func (k *Keys) Encrypt(gist *gists.GistFile, profile string) (*gists.GistFile, error) {
+	recipients, err := k.recipients(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := *gist
+	encrypted.Files = make([]gists.GistFileBody, 0, len(gist.Files))
+	for _, f := range gist.Files {
+		var buf bytes.Buffer
+		armored := armor.NewWriter(&buf)
+		w, err := age.Encrypt(armored, recipients...)
+		if err != nil {
+			return nil, fmt.Errorf("could not encrypt %s -> %s", f.Filename, err)
+		}
+		if _, err := w.Write([]byte(f.Content)); err != nil {
+			return nil, fmt.Errorf("could not encrypt %s -> %s", f.Filename, err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("could not encrypt %s -> %s", f.Filename, err)
+		}
+		if err := armored.Close(); err != nil {
+			return nil, fmt.Errorf("could not encrypt %s -> %s", f.Filename, err)
+		}
+		encrypted.Files = append(encrypted.Files, gists.GistFileBody{Filename: f.Filename + Suffix,
+			Content: buf.String()})
+	}
+	return &encrypted, nil
+}
+
+//Decrypt decrypts, in place, the files of gist that are named with Suffix and hold an armored age file, and removes
+// the suffix from their names. It returns how they were encrypted, "" if none were, gists.EncryptPassphrase if with a
+// passphrase and gists.EncryptDefault otherwise, as the profile cannot be told from the recipients. gist is only
+// changed once every file is decrypted, so a gist that cannot be is left encrypted as a whole.
+func (k *Keys) Decrypt(gist *gists.GistFile) (string, error) {
+	encryption := ""
+	files := append([]gists.GistFileBody(nil), gist.Files...)
+	for i, f := range gist.Files {
+		if !strings.HasSuffix(f.Filename, Suffix) || !strings.HasPrefix(strings.TrimSpace(f.Content), armor.Header) {
+			continue
+		}
+		data, err := ioutil.ReadAll(armor.NewReader(strings.NewReader(f.Content)))
+		if err != nil {
+			return "", fmt.Errorf("could not decrypt %s -> %s", f.Filename, err)
+		}
+		header, err := age.ExtractHeader(bytes.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("could not decrypt %s -> %s", f.Filename, err)
+		}
+		if len(k.Identities) == 0 {
+			return "", fmt.Errorf("could not decrypt %s, there are no identities, see %s or set %s", f.Filename,
+				config.IdentityFile, PassphraseEnv)
+		}
+		r, err := age.Decrypt(bytes.NewReader(data), k.Identities...)
+		if err != nil {
+			return "", fmt.Errorf("could not decrypt %s -> %s", f.Filename, err)
+		}
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", fmt.Errorf("could not decrypt %s -> %s", f.Filename, err)
+		}
+
+		files[i] = gists.GistFileBody{Filename: strings.TrimSuffix(f.Filename, Suffix), Content: string(content)}
+		encryption = gists.EncryptDefault
+		if bytes.Contains(header, []byte("\n-> scrypt ")) {
+			encryption = gists.EncryptPassphrase
+		}
+	}
+	gist.Files = files
+	return encryption, nil
+}
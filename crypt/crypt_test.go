@@ -0,0 +1,124 @@
+package crypt
+
+import (
+	"filippo.io/age"
+	"github.com/martinomburajr/gist/gists"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeys_Encrypt(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphrase, err := age.NewScryptIdentity("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &Keys{
+		Profiles:   map[string][]age.Recipient{"team": {identity.Recipient()}},
+		Identities: []age.Identity{identity, passphrase},
+		passphrase: "correct horse",
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		want    string
+		wantErr bool
+	}{
+		{"profile", "team", gists.EncryptDefault, false},
+		{"passphrase", gists.EncryptPassphrase, gists.EncryptPassphrase, false},
+		{"unknown profile", "ops", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gist := &gists.GistFile{
+				Description: "notes",
+				Files:       []gists.GistFileBody{{Filename: "notes.md", Content: "the password is hunter2\n"}},
+			}
+			encrypted, err := keys.Encrypt(gist, tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Keys.Encrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if f := encrypted.Files[0]; f.Filename != "notes.md"+Suffix || strings.Contains(f.Content, "hunter2") {
+				t.Errorf("Keys.Encrypt() = %+v, want an armored notes.md%s", f, Suffix)
+			}
+
+			got, err := keys.Decrypt(encrypted)
+			if err != nil {
+				t.Fatalf("Keys.Decrypt() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Keys.Decrypt() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(encrypted, gist) {
+				t.Errorf("Keys.Decrypt() = %+v, want %+v", encrypted, gist)
+			}
+		})
+	}
+}
+
+func TestKeys_Decrypt_plain(t *testing.T) {
+	gist := &gists.GistFile{Files: []gists.GistFileBody{{Filename: "key.age", Content: "not armored"}}}
+	got, err := (&Keys{}).Decrypt(gist)
+	if err != nil || got != "" || gist.Files[0].Filename != "key.age" {
+		t.Errorf("Keys.Decrypt() = %q, %v, %+v, want the file left alone", got, err, gist.Files[0])
+	}
+}
+
+func TestKeys_Decrypt_noIdentity(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &Keys{Profiles: map[string][]age.Recipient{gists.EncryptDefault: {identity.Recipient()}}}
+	gist := &gists.GistFile{Files: []gists.GistFileBody{{Filename: "a.txt", Content: "a"}}}
+	encrypted, err := keys.Encrypt(gist, gists.EncryptDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.Identities = []age.Identity{other}
+	if _, err := keys.Decrypt(encrypted); err == nil {
+		t.Errorf("Keys.Decrypt() with the wrong identity should fail")
+	}
+}
+
+func TestKeys_Decrypt_partial(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine, err := (&Keys{Profiles: map[string][]age.Recipient{"team": {identity.Recipient()}}}).Encrypt(
+		&gists.GistFile{Files: []gists.GistFileBody{{Filename: "a.txt", Content: "a"}}}, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := (&Keys{Profiles: map[string][]age.Recipient{"ops": {other.Recipient()}}}).Encrypt(
+		&gists.GistFile{Files: []gists.GistFileBody{{Filename: "b.txt", Content: "b"}}}, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gist := &gists.GistFile{Files: []gists.GistFileBody{mine.Files[0], theirs.Files[0]}}
+	keys := &Keys{Identities: []age.Identity{identity}}
+	if _, err := keys.Decrypt(gist); err == nil {
+		t.Fatalf("Keys.Decrypt() of a file for another identity should fail")
+	}
+	if gist.Files[0] != mine.Files[0] || gist.Files[1] != theirs.Files[0] {
+		t.Errorf("Keys.Decrypt() that failed left %+v, want every file encrypted", gist.Files)
+	}
+}
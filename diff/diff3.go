@@ -0,0 +1,198 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//Chunk is a part of three versions of a text: a base and two versions derived from it, local and remote. The lines
+// of a stable chunk are the same in all three versions, the others hold the lines that differ between them.
+type Chunk struct {
+	Base   []string
+	Local  []string
+	Remote []string
+
+	//BaseLine, LocalLine and RemoteLine are the numbers of the first line of the chunk in each version
+	BaseLine   int
+	LocalLine  int
+	RemoteLine int
+
+	Stable bool
+}
+
+//Conflict reports whether local and remote changed the chunk in different ways
+func (c Chunk) Conflict() bool {
+	return !c.Stable && !equal(c.Local, c.Base) && !equal(c.Remote, c.Base) && !equal(c.Local, c.Remote)
+}
+
+//Lines splits text into lines, keeping their line endings
+func Lines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+//Diff3 splits three versions of a text into chunks, in the manner of diff3. Stable chunks alternate with the chunks
+// that changed in local, remote or both.
+func Diff3(base, local, remote []string) []Chunk {
+	toLocal := match(base, local)
+	toRemote := match(base, remote)
+
+	var chunks []Chunk
+	i, j, k := 0, 0, 0
+	for i < len(base) || j < len(local) || k < len(remote) {
+		//lines matched in both versions at the current position form a stable chunk
+		n := 0
+		for i+n < len(base) && toLocal[i+n] == j+n && toRemote[i+n] == k+n {
+			n++
+		}
+		if n > 0 {
+			chunks = append(chunks, Chunk{
+				Base: base[i : i+n], Local: local[j : j+n], Remote: remote[k : k+n],
+				BaseLine: i + 1, LocalLine: j + 1, RemoteLine: k + 1,
+				Stable: true,
+			})
+			i, j, k = i+n, j+n, k+n
+			continue
+		}
+
+		//otherwise the chunk changed and runs up to the next base line matched in both versions
+		o := i
+		for o < len(base) && (toLocal[o] < 0 || toRemote[o] < 0) {
+			o++
+		}
+		endLocal, endRemote := len(local), len(remote)
+		if o < len(base) {
+			endLocal, endRemote = toLocal[o], toRemote[o]
+		}
+		chunks = append(chunks, Chunk{
+			Base: base[i:o], Local: local[j:endLocal], Remote: remote[k:endRemote],
+			BaseLine: i + 1, LocalLine: j + 1, RemoteLine: k + 1,
+		})
+		i, j, k = o, endLocal, endRemote
+	}
+	return chunks
+}
+
+//Write writes the chunks that changed, each headed by its position in the three versions of the text named name.
+// Conflicting chunks show all three versions between conflict markers, the others only the version that changed.
+func Write(w io.Writer, name string, chunks []Chunk) error {
+	for _, c := range chunks {
+		if c.Stable {
+			continue
+		}
+
+		var err error
+		switch {
+		case c.Conflict():
+			_, err = fmt.Fprintf(w, "@@ %s conflict: local %s base %s remote %s @@\n", name,
+				span(c.LocalLine, c.Local), span(c.BaseLine, c.Base), span(c.RemoteLine, c.Remote))
+			for _, section := range []struct {
+				marker string
+				lines  []string
+			}{
+				{"<<<<<<< local", c.Local},
+				{"||||||| base", c.Base},
+				{"=======", c.Remote},
+				{">>>>>>> remote", nil},
+			} {
+				if err == nil {
+					err = writeLines(w, section.marker, section.lines)
+				}
+			}
+		case equal(c.Remote, c.Base):
+			_, err = fmt.Fprintf(w, "@@ %s changed locally: base %s local %s @@\n", name, span(c.BaseLine, c.Base),
+				span(c.LocalLine, c.Local))
+			if err == nil {
+				err = writeLines(w, "", c.Local)
+			}
+		default:
+			_, err = fmt.Fprintf(w, "@@ %s changed remotely: base %s remote %s @@\n", name, span(c.BaseLine, c.Base),
+				span(c.RemoteLine, c.Remote))
+			if err == nil {
+				err = writeLines(w, "", c.Remote)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//writeLines writes marker, if any, followed by lines, making sure every line ends in a newline
+func writeLines(w io.Writer, marker string, lines []string) error {
+	if marker != "" {
+		if _, err := fmt.Fprintln(w, marker); err != nil {
+			return err
+		}
+	}
+	for _, line := range lines {
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//span formats the lines of a chunk starting at line as start,count
+func span(line int, lines []string) string {
+	return fmt.Sprintf("%d,%d", line, len(lines))
+}
+
+//match returns, for every line of a, the index of the line of b it is matched with in a longest common subsequence
+// of a and b, or -1 if it has no match.
+func match(a, b []string) []int {
+	//lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			matches[i] = j
+			i, j = i+1, j+1
+		case j < len(b) && lcs[i][j+1] > lcs[i+1][j]:
+			j++
+		default:
+			matches[i] = -1
+			i++
+		}
+	}
+	return matches
+}
+
+//equal reports whether a and b hold the same lines
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiff3(t *testing.T) {
+	base := Lines("a\nb\nc\nd\ne\n")
+	tests := []struct {
+		name          string
+		local         string
+		remote        string
+		wantChunks    int
+		wantConflicts int
+	}{
+		{"unchanged", "a\nb\nc\nd\ne\n", "a\nb\nc\nd\ne\n", 1, 0},
+		{"local only", "a\nB\nc\nd\ne\n", "a\nb\nc\nd\ne\n", 3, 0},
+		{"both apart", "a\nB\nc\nd\ne\n", "a\nb\nc\nD\ne\n", 5, 0},
+		{"same change", "a\nB\nc\nd\ne\n", "a\nB\nc\nd\ne\n", 3, 0},
+		{"conflict", "a\nB\nc\nd\ne\n", "a\nX\nc\nd\ne\n", 3, 1},
+		{"appended", "a\nb\nc\nd\ne\nf\n", "a\nb\nc\nd\ne\ng\n", 2, 1},
+		{"deleted and changed", "a\nc\nd\ne\n", "a\nX\nc\nd\ne\n", 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, remote := Lines(tt.local), Lines(tt.remote)
+			chunks := Diff3(base, local, remote)
+
+			conflicts := 0
+			var gotBase, gotLocal, gotRemote []string
+			for _, c := range chunks {
+				if c.Conflict() {
+					conflicts++
+				}
+				gotBase = append(gotBase, c.Base...)
+				gotLocal = append(gotLocal, c.Local...)
+				gotRemote = append(gotRemote, c.Remote...)
+			}
+			if len(chunks) != tt.wantChunks || conflicts != tt.wantConflicts {
+				t.Errorf("Diff3() = %d chunks with %d conflicts, want %d with %d", len(chunks), conflicts,
+					tt.wantChunks, tt.wantConflicts)
+			}
+			if !reflect.DeepEqual(gotBase, base) || !reflect.DeepEqual(gotLocal, local) ||
+				!reflect.DeepEqual(gotRemote, remote) {
+				t.Errorf("Diff3() chunks do not add up to the three versions: %+v", chunks)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	chunks := Diff3(Lines("a\nb\nc\n"), Lines("a\nlocal\nc\n"), Lines("a\nremote\nc\n"))
+	var out bytes.Buffer
+	if err := Write(&out, "main.go", chunks); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	want := "@@ main.go conflict: local 2,1 base 2,1 remote 2,1 @@\n" +
+		"<<<<<<< local\nlocal\n||||||| base\nb\n=======\nremote\n>>>>>>> remote\n"
+	if got := out.String(); got != want {
+		t.Errorf("Write() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package gists
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//RemoteSuffix is appended to the path of a file to save the remote version of it alongside, rather than over, the
+// local one, e.g. when both were edited since they were last in sync.
+const RemoteSuffix = ".remote"
+
+//Merge returns the content of the file changed so that it publishes the files of remote, the reverse of
+// GetFileBodies. The GOGIST header of a file whose header is stripped is kept, as are the lines outside of its
+// regions, and the indentation removed from dedented regions is put back. Files with nested regions, or whose remote
+// files do not match the ones the file publishes, cannot be merged.
+func (g *GistParser) Merge(remote *GistFile) ([]byte, error) {
+	local, err := g.GetFileBodies()
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make(map[string]string, len(remote.Files))
+	for _, f := range remote.Files {
+		contents[f.Filename] = f.Content
+	}
+	for _, f := range local {
+		if _, ok := contents[f.Filename]; !ok || len(local) != len(remote.Files) {
+			return nil, fmt.Errorf("gist %s has files %s but %s publishes %s", remote.ID, fileNames(remote.Files),
+				g.Filepath, fileNames(local))
+		}
+	}
+
+	regions, _ := ParseRegions(g.Filepath, g.fileContents)
+	if len(regions) == 0 {
+		return g.mergeWhole(local[0].Content, contents[local[0].Filename])
+	}
+
+	dedent, err := g.GetDedent()
+	if err != nil {
+		return nil, err
+	}
+	raw := strings.SplitAfter(string(g.fileContents), "\n")
+	out := make([]string, 0, len(raw))
+	next := 0
+	for i, r := range regions {
+		if i > 0 && r.StartLine < regions[i-1].EndLine {
+			return nil, fmt.Errorf("%s has nested regions, they cannot be merged", g.Filepath)
+		}
+
+		content := contents[local[i].Filename]
+		if dedent {
+			content = indent(content, sharedIndent(r.Content))
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += lineEnding(raw[r.StartLine-1])
+		}
+		out = append(out, raw[next:r.StartLine]...)
+		out = append(out, content)
+		next = r.EndLine - 1
+	}
+	out = append(out, raw[next:]...)
+	return []byte(strings.Join(out, "")), nil
+}
+
+//mergeWhole returns the content of a file published whole as published, changed to publish updated instead. If the
+// header was stripped from published it is put back where it was.
+func (g *GistParser) mergeWhole(published, updated string) ([]byte, error) {
+	content := string(g.fileContents)
+	if content == published {
+		return []byte(updated), nil
+	}
+
+	//the stripped header is a single block of lines, find where it starts and check that removing it gives published
+	start := 0
+	for start < len(published) && start < len(content) && content[start] == published[start] {
+		start++
+	}
+	start = strings.LastIndex(published[:start], "\n") + 1
+	end := start + len(content) - len(published)
+	if end < start || content[end:] != published[start:] || !strings.HasPrefix(updated, published[:start]) {
+		return nil, fmt.Errorf("cannot tell where the GOGIST header of %s goes in the remote content", g.Filepath)
+	}
+	return []byte(updated[:start] + content[start:end] + updated[start:]), nil
+}
+
+//Apply changes the file, and its sidecar if it has one, to publish remote. The content is changed as by Merge, and
+// the description and visibility are set in the header.
+func (g *GistParser) Apply(remote *GistFile) error {
+	content, err := g.Merge(remote)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(content, g.fileContents) {
+		if err := writeFileAtomic(g.Filepath, content); err != nil {
+			return fmt.Errorf("could not write %s -> %s", g.Filepath, err)
+		}
+	}
+
+	var fields [][2]string
+	if description, err := g.GetDescription(); err != nil || description != remote.Description {
+		fields = append(fields, [2]string{"description", remote.Description})
+	}
+	if public, err := g.GetPublic(); err != nil || public != remote.Public {
+		fields = append(fields, [2]string{"public", strconv.FormatBool(remote.Public)})
+	}
+	return g.setFields(fields)
+}
+
+//indent adds prefix to every non blank line of content
+func indent(content, prefix string) string {
+	if prefix == "" {
+		return content
+	}
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+//fileNames returns the sorted, comma separated names of files
+func fileNames(files []GistFileBody) string {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Filename)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
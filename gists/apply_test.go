@@ -0,0 +1,100 @@
+package gists
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGistParser_Merge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := "// start gist\n// description: d\n// end gist\n"
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		remote  []GistFileBody
+		want    string
+		wantErr bool
+	}{
+		{"whole file", "main.go", header + "package main\n",
+			[]GistFileBody{{"main.go", "// start gist\n// description: e\n// end gist\npackage other\n"}},
+			"// start gist\n// description: e\n// end gist\npackage other\n", false},
+		{"stripped header", "main.go", "// start gist\n// description: d\n// strip: true\n// end gist\n\npackage main\n",
+			[]GistFileBody{{"main.go", "package other\n"}},
+			"// start gist\n// description: d\n// strip: true\n// end gist\n\npackage other\n", false},
+		{"stripped after shebang", "run.sh", "#!/bin/sh\n# start gist\n# description: d\n# strip: true\n# end gist\necho a\n",
+			[]GistFileBody{{"run.sh", "#!/bin/sh\necho b\n"}},
+			"#!/bin/sh\n# start gist\n# description: d\n# strip: true\n# end gist\necho b\n", false},
+		{"regions", "main.go", header + "package main\n// gist:region one\nfunc one() {}\n// gist:endregion\n" +
+			"func skip() {}\n// gist:region two\nfunc two() {}\n// gist:endregion\n",
+			[]GistFileBody{{"main-one.go", "func uno() {}\n"}, {"main-two.go", "func dos() {}"}},
+			header + "package main\n// gist:region one\nfunc uno() {}\n// gist:endregion\n" +
+				"func skip() {}\n// gist:region two\nfunc dos() {}\n// gist:endregion\n", false},
+		{"dedented region", "main.go", "// start gist\n// description: d\n// dedent: true\n// end gist\n" +
+			"func main() {\n\t// gist:region body\n\tif true {\n\t\treturn\n\t}\n\t// gist:endregion\n}\n",
+			[]GistFileBody{{"main.go", "if false {\n\n\treturn\n}\n"}},
+			"// start gist\n// description: d\n// dedent: true\n// end gist\n" +
+				"func main() {\n\t// gist:region body\n\tif false {\n\n\t\treturn\n\t}\n\t// gist:endregion\n}\n", false},
+		{"other files", "main.go", header + "package main\n", []GistFileBody{{"other.go", "package main\n"}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			g := &GistParser{Filepath: path}
+			got, err := g.Merge(&GistFile{Files: tt.remote})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GistParser.Merge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("GistParser.Merge() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGistParser_Apply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "data.json")
+	if err := ioutil.WriteFile(path, []byte("{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path+SidecarSuffix, []byte("description: old\nid: abc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	g := &GistParser{Filepath: path}
+	remote := &GistFile{Description: "new: improved", Public: true, Files: []GistFileBody{{"data.json", "[]\n"}}}
+	if err := g.Apply(remote); err != nil {
+		t.Fatalf("GistParser.Apply() error = %v", err)
+	}
+	if got := readFile(path); got != "[]\n" {
+		t.Errorf("GistParser.Apply() wrote %q, want %q", got, "[]\n")
+	}
+	want := "description: new: improved\nid: abc\npublic: true\n"
+	if got := readFile(path + SidecarSuffix); got != want {
+		t.Errorf("GistParser.Apply() wrote sidecar %q, want %q", got, want)
+	}
+
+	gist, err := g.ToGist()
+	if err != nil {
+		t.Fatalf("GistParser.ToGist() error = %v", err)
+	}
+	if gist.Description != remote.Description || gist.Public != remote.Public {
+		t.Errorf("GistParser.ToGist() = %+v after Apply, want %+v", gist, remote)
+	}
+}
@@ -0,0 +1,118 @@
+package gists
+
+import (
+	"strings"
+)
+
+//commentSyntax describes how comments are written in a language
+type commentSyntax struct {
+	//line holds the prefixes that start a comment running to the end of the line
+	line []string
+
+	//block holds the opening and closing delimiters of comments that may span several lines
+	block [][2]string
+}
+
+//genericSyntax recognises the comment styles of most languages. It is used when nothing more specific is known
+// about a file.
+var genericSyntax = commentSyntax{
+	line:  []string{"//", "#", "--", ";", "%"},
+	block: [][2]string{{"/*", "*/"}, {"<!--", "-->"}},
+}
+
+//commentLine is a line of a file as classified by scanComments
+type commentLine struct {
+	//isComment is true if the line is entirely a comment, or lies within a block comment
+	isComment bool
+
+	//text is the comment with its delimiters removed
+	text string
+
+	//column is the column text starts at, counting from 1
+	column int
+
+	//block is true for lines within a block comment, opens and closes mark the lines holding its delimiters
+	block  bool
+	opens  bool
+	closes bool
+}
+
+//scanComments classifies every line as comment or code, tracking block comments across lines.
+func scanComments(lines []string, syntax commentSyntax) []commentLine {
+	out := make([]commentLine, len(lines))
+	closer := ""
+	for i, line := range lines {
+		rest := strings.TrimLeft(line, " \t")
+		offset := len(line) - len(rest)
+
+		switch {
+		case closer != "":
+			if closer == "*/" && strings.HasPrefix(rest, "*") && !strings.HasPrefix(rest, "*/") {
+				rest = rest[1:]
+				offset++
+			}
+			closes := false
+			if j := strings.Index(rest, closer); j >= 0 {
+				rest = rest[:j]
+				closer = ""
+				closes = true
+			}
+			out[i] = commentLine{isComment: true, text: rest, column: offset + 1, block: true, closes: closes}
+		default:
+			if open, close, ok := syntax.blockOpener(rest); ok {
+				rest = rest[len(open):]
+				offset += len(open)
+				for open == "/*" && strings.HasPrefix(rest, "*") && !strings.HasPrefix(rest, "*/") {
+					rest = rest[1:]
+					offset++
+				}
+				closes := false
+				if j := strings.Index(rest, close); j >= 0 {
+					rest = rest[:j]
+					closes = true
+				} else {
+					closer = close
+				}
+				out[i] = commentLine{isComment: true, text: rest, column: offset + 1, block: true, opens: true,
+					closes: closes}
+			} else if prefix, ok := syntax.linePrefix(rest); ok {
+				out[i] = commentLine{isComment: true, text: rest[len(prefix):], column: offset + len(prefix) + 1}
+			} else {
+				closer = syntax.unclosedBlock(rest)
+				out[i] = commentLine{text: line, column: 1}
+			}
+		}
+	}
+	return out
+}
+
+//blockOpener returns the block comment delimiters if s starts with a block comment
+func (c commentSyntax) blockOpener(s string) (string, string, bool) {
+	for _, pair := range c.block {
+		if strings.HasPrefix(s, pair[0]) {
+			return pair[0], pair[1], true
+		}
+	}
+	return "", "", false
+}
+
+//linePrefix returns the line comment prefix s starts with
+func (c commentSyntax) linePrefix(s string) (string, bool) {
+	for _, prefix := range c.line {
+		if strings.HasPrefix(s, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+//unclosedBlock returns the closing delimiter of a block comment opened, but not closed, within a line of code.
+func (c commentSyntax) unclosedBlock(code string) string {
+	for _, pair := range c.block {
+		j := strings.LastIndex(code, pair[0])
+		if j >= 0 && !strings.Contains(code[j+len(pair[0]):], pair[1]) {
+			return pair[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,332 @@
+package gists
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//SidecarSuffix is appended to the path of a file to find its sidecar, a YAML file holding the GOGIST fields for files
+// that cannot carry them, e.g. JSON. Fields in a sidecar take precedence over those within the file.
+//
+//	# main.json.gist.yaml
+//	description: Sample configuration
+//	public: false
+const SidecarSuffix = ".gist.yaml"
+
+var tomlFieldRegexp = regexp.MustCompile(`^([A-Za-z0-9_-]+)[ \t]*=[ \t]*(.*)$`)
+
+//parseFrontMatter parses GOGIST fields from YAML front matter, delimited by --- lines, or TOML front matter,
+// delimited by +++ lines, at the very top of a file. It returns nil if there is no front matter or it has no 'gist'
+// key. The fields are either nested under the 'gist' key
+//
+//	---
+//	title: Blog post
+//	gist:
+//	  description: Some awesome gist
+//	  public: false
+//	---
+//
+//or, when 'gist' is true, taken from the top level so they can be shared with other tools.
+//
+//	+++
+//	gist = true
+//	description = "Some awesome gist"
+//	+++
+func parseFrontMatter(file string, lines []string) *Header {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	delimiter := strings.TrimSpace(lines[0])
+	if delimiter != "---" && delimiter != "+++" {
+		return nil
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		t := strings.TrimSpace(lines[i])
+		if t == delimiter || (delimiter == "---" && t == "...") {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil
+	}
+
+	h := &Header{File: file, StartLine: 1, EndLine: end + 1}
+	body := make([]headerLine, 0, end-1)
+	for i := 1; i < end; i++ {
+		text := strings.TrimSpace(lines[i])
+		body = append(body, headerLine{
+			line:    i + 1,
+			text:    lines[i],
+			column:  strings.Index(lines[i], text) + 1,
+			comment: true,
+			blank:   text == "" || strings.HasPrefix(text, "#"),
+		})
+	}
+
+	var found bool
+	if delimiter == "---" {
+		h.Source = SourceYAML
+		found = h.parseYAMLFrontMatter(body)
+	} else {
+		h.Source = SourceTOML
+		found = h.parseTOMLFrontMatter(body)
+	}
+	if !found {
+		return nil
+	}
+	return h
+}
+
+//parseYAMLFrontMatter looks for the top level 'gist' key and parses the fields it refers to.
+func (h *Header) parseYAMLFrontMatter(body []headerLine) bool {
+	top := -1
+	for _, l := range body {
+		if !l.blank && (top < 0 || l.column < top) {
+			top = l.column
+		}
+	}
+
+	for i, l := range body {
+		if l.blank || l.column != top {
+			continue
+		}
+		match := fieldRegexp.FindStringSubmatch(strings.TrimSpace(l.text))
+		if match == nil || strings.ToLower(match[1]) != "gist" {
+			continue
+		}
+
+		value := strings.TrimSpace(match[2])
+		if value == "" {
+			j := i + 1
+			for j < len(body) && (body[j].blank || body[j].column > l.column) {
+				j++
+			}
+			h.meta = append(h.meta, [2]int{l.line, body[j-1].line})
+			h.parseFields(body[i+1 : j])
+			return true
+		}
+
+		on, err := strconv.ParseBool(value)
+		if err != nil || !on {
+			return false
+		}
+		h.allowUnknown = true
+		h.parseFields(append(body[:i:i], body[i+1:]...))
+		h.meta = append(h.meta, [2]int{l.line, l.line})
+		h.addKnownFieldLines()
+		return true
+	}
+	return false
+}
+
+//addKnownFieldLines marks the lines of known fields for removal by stripFrontMatter, leaving the keys meant for
+// other tools in place.
+func (h *Header) addKnownFieldLines() {
+	for _, f := range h.Fields {
+		if isKnownKey(f.Key) {
+			h.meta = append(h.meta, [2]int{f.Line, f.EndLine})
+		}
+	}
+}
+
+//parseTOMLFrontMatter parses the fields of a [gist] table, or the top level keys if 'gist = true' is set. Values may
+// be basic or literal strings, multi-line basic strings, booleans or bare words.
+func (h *Header) parseTOMLFrontMatter(body []headerLine) bool {
+	var top, table []Field
+	var tableLines [2]int
+	enabled, enabledLine := false, 0
+	inTable, inOther := false, false
+
+	for i := 0; i < len(body); i++ {
+		l := body[i]
+		if l.blank {
+			continue
+		}
+		text := strings.TrimSpace(l.text)
+		if strings.HasPrefix(text, "[") {
+			inTable = strings.TrimSpace(strings.Trim(text, "[]")) == "gist"
+			inOther = !inTable
+			if inTable {
+				tableLines = [2]int{l.line, l.line}
+			}
+			continue
+		}
+
+		match := tomlFieldRegexp.FindStringSubmatch(text)
+		if match == nil {
+			if inTable {
+				h.addDiagnostic(SeverityWarning, l.line, l.column, "expected 'key = value', line is ignored")
+			}
+			continue
+		}
+
+		field := Field{Key: strings.ToLower(match[1]), Line: l.line, EndLine: l.line, Column: l.column}
+		raw := strings.TrimSpace(match[2])
+		if strings.HasPrefix(raw, `"""`) {
+			rest := raw[3:]
+			for !strings.Contains(rest, `"""`) && i+1 < len(body) {
+				i++
+				rest += "\n" + body[i].text
+				field.EndLine = body[i].line
+			}
+			raw = `"` + strings.Replace(strings.TrimPrefix(rest, "\n"), `"""`, `"`, 1)
+		}
+		value, err := parseQuotedTOML(raw)
+		if err != "" {
+			h.addDiagnostic(SeverityError, l.line, l.column, "%s", err)
+		}
+		field.Value = value
+
+		switch {
+		case inOther:
+			continue
+		case inTable:
+			table = append(table, field)
+			tableLines[1] = field.EndLine
+		case field.Key == "gist":
+			enabled, _ = strconv.ParseBool(value)
+			enabledLine = field.Line
+		default:
+			top = append(top, field)
+		}
+	}
+
+	if tableLines[0] > 0 {
+		h.meta = append(h.meta, tableLines)
+		for _, f := range table {
+			h.addField(f.Key, f)
+		}
+		return true
+	}
+	if !enabled {
+		return false
+	}
+	h.allowUnknown = true
+	for _, f := range top {
+		h.addField(f.Key, f)
+	}
+	h.meta = append(h.meta, [2]int{enabledLine, enabledLine})
+	h.addKnownFieldLines()
+	return true
+}
+
+//parseQuotedTOML returns the value of a TOML string, boolean or bare word
+func parseQuotedTOML(raw string) (string, string) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		return parseQuoted(raw)
+	case strings.HasPrefix(raw, "'"):
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return raw, "unterminated quoted value"
+		}
+		return raw[1 : end+1], ""
+	}
+	if i := strings.Index(raw, "#"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	return raw, ""
+}
+
+//stripFrontMatter removes the lines holding the GOGIST fields from the front matter, or the whole front matter if
+// nothing else is left in it.
+func (h *Header) stripFrontMatter(content []byte) []byte {
+	raw := strings.SplitAfter(string(content), "\n")
+
+	drop := make([]bool, len(raw))
+	for _, r := range h.meta {
+		for i := r[0]; i <= r[1]; i++ {
+			drop[i-1] = true
+		}
+	}
+
+	empty := true
+	for i := h.StartLine; i < h.EndLine-1; i++ {
+		if !drop[i] && strings.TrimSpace(raw[i]) != "" {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		for i := h.StartLine - 1; i < h.EndLine; i++ {
+			drop[i] = true
+		}
+		if h.EndLine < len(raw) && strings.TrimSpace(raw[h.EndLine]) == "" {
+			drop[h.EndLine] = true
+		}
+	}
+
+	out := make([]string, 0, len(raw))
+	for i, line := range raw {
+		if !drop[i] {
+			out = append(out, line)
+		}
+	}
+	return []byte(strings.Join(out, ""))
+}
+
+//ParseSidecar parses a sidecar file, whose top level YAML keys are the GOGIST fields.
+func ParseSidecar(file string, content []byte) *Header {
+	h := &Header{File: file, Source: SourceSidecar, Sidecar: file}
+	lines := splitLines(string(content))
+	body := make([]headerLine, 0, len(lines))
+	for i, line := range lines {
+		text := strings.TrimSpace(line)
+		if i == 0 && text == "---" {
+			continue
+		}
+		body = append(body, headerLine{
+			line:    i + 1,
+			text:    line,
+			column:  strings.Index(line, text) + 1,
+			comment: true,
+			blank:   text == "" || strings.HasPrefix(text, "#"),
+		})
+	}
+	h.parseFields(body)
+	return h
+}
+
+//readSidecar returns the parsed sidecar of the file at path, or nil if it has none.
+func readSidecar(path string) (*Header, error) {
+	sidecar := path + SidecarSuffix
+	data, err := ioutil.ReadFile(sidecar)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseSidecar(sidecar, data), nil
+}
+
+//merge adds the fields of a sidecar to the header, replacing fields with the same key.
+func (h *Header) merge(sidecar *Header) {
+	h.Sidecar = sidecar.File
+	if !h.inFile() {
+		h.Source = SourceSidecar
+		h.Fields = sidecar.Fields
+		h.Diagnostics = sidecar.Diagnostics
+		return
+	}
+
+	h.Diagnostics = append(h.Diagnostics, sidecar.Diagnostics...)
+	for _, f := range sidecar.Fields {
+		replaced := false
+		for i := range h.Fields {
+			if h.Fields[i].Key == f.Key {
+				h.Fields[i] = f
+				replaced = true
+			}
+		}
+		if !replaced {
+			h.Fields = append(h.Fields, f)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package gists
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     map[string]string
+		source   string
+		warnings int
+	}{
+		{"yaml nested", "---\ntitle: Post\ngist:\n  description: Some awesome gist\n  public: false\n---\n# Post\n",
+			map[string]string{"description": "Some awesome gist", "public": "false"}, SourceYAML, 0},
+		{"yaml top level", "---\ngist: true\ntitle: Post\ndescription: >-\n  folded\n  value\n...\nbody\n",
+			map[string]string{"title": "Post", "description": "folded value"}, SourceYAML, 0},
+		{"yaml nested unknown key", "---\ngist:\n  colour: red\n---\n",
+			map[string]string{"colour": "red"}, SourceYAML, 1},
+		{"toml table", "+++\ntitle = \"Post\"\n[gist]\ndescription = 'Some awesome gist'\npublic = false\n+++\n",
+			map[string]string{"description": "Some awesome gist", "public": "false"}, SourceTOML, 0},
+		{"toml top level", "+++\ngist = true\ndescription = \"\"\"\nfirst\nsecond\"\"\"\n+++\n",
+			map[string]string{"description": "first\nsecond"}, SourceTOML, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := ParseHeader("post.md", []byte(tt.content))
+			if !h.Found() || h.Err() != nil {
+				t.Fatalf("ParseHeader() found = %v, err = %v", h.Found(), h.Err())
+			}
+			got := map[string]string{}
+			for _, f := range h.Fields {
+				got[f.Key] = f.Value
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHeader() fields = %v, want %v", got, tt.want)
+			}
+			if h.Source != tt.source {
+				t.Errorf("ParseHeader() source = %q, want %q", h.Source, tt.source)
+			}
+			if len(h.Warnings()) != tt.warnings {
+				t.Errorf("ParseHeader() warnings = %v, want %d", h.Warnings(), tt.warnings)
+			}
+		})
+	}
+}
+
+func TestParseFrontMatter_NotGistable(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no gist key", "---\ntitle: Post\n---\n"},
+		{"gist false", "---\ngist: false\ndescription: d\n---\n"},
+		{"unterminated", "---\ngist:\n  description: d\n"},
+		{"not at the top", "\n---\ngist:\n  description: d\n---\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if h := ParseHeader("post.md", []byte(tt.content)); h.Found() {
+				t.Errorf("ParseHeader() found a header in %q", tt.content)
+			}
+		})
+	}
+}
+
+func TestHeader_StripFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"whole block", "---\ngist:\n  description: d\n---\n\n# Post\n", "# Post\n"},
+		{"other keys kept", "---\ntitle: Post\ngist:\n  description: d\n---\n# Post\n", "---\ntitle: Post\n---\n# Post\n"},
+		{"top level keeps unknown keys", "---\ngist: true\ntitle: Post\ndescription: d\n---\n",
+			"---\ntitle: Post\n---\n"},
+		{"toml table", "+++\n[gist]\ndescription = \"d\"\n+++\nbody\n", "body\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := ParseHeader("post.md", []byte(tt.content))
+			if got := string(h.Strip([]byte(tt.content))); got != tt.want {
+				t.Errorf("Header.Strip() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGistParser_Sidecar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sidecar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	plain := write("data.json", "{\"a\": 1}\n")
+	write("data.json"+SidecarSuffix, "# metadata for data.json\ndescription: Sample data\npublic: false\n")
+	both := write("main.go", "// start gist\n// description: in file\n// author: me\n// end gist\npackage main\n")
+	write("main.go"+SidecarSuffix, "description: from sidecar\n")
+
+	tests := []struct {
+		name        string
+		path        string
+		description string
+		author      string
+		public      bool
+	}{
+		{"sidecar only", plain, "Sample data", "", false},
+		{"sidecar wins", both, "from sidecar", "me", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &GistParser{Filepath: tt.path}
+			if err := g.IsGistable(); err != nil {
+				t.Fatalf("GistParser.IsGistable() error = %v", err)
+			}
+			if got, _ := g.GetDescription(); got != tt.description {
+				t.Errorf("GistParser.GetDescription() = %q, want %q", got, tt.description)
+			}
+			if got, _ := g.GetAuthor(); got != tt.author {
+				t.Errorf("GistParser.GetAuthor() = %q, want %q", got, tt.author)
+			}
+			if got, _ := g.GetPublic(); got != tt.public {
+				t.Errorf("GistParser.GetPublic() = %v, want %v", got, tt.public)
+			}
+		})
+	}
+}
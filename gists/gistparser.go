@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -12,6 +14,25 @@ import (
 type GistParser struct {
 	Filepath string `json:"filepath"`
 	fileContents []byte
+
+	//Strip removes the GOGIST header from the published content when the file does not say otherwise with a
+	// 'strip' key, see GetStrip. The file itself is never modified.
+	Strip bool `json:"strip"`
+
+	//Filename, when set, is the name the file is published under regardless of its 'filename' key, see GetFilename.
+	Filename string `json:"filename,omitempty"`
+
+	//Dedent removes the indentation shared by the lines of each region when the file does not say otherwise with a
+	// 'dedent' key, see GetDedent.
+	Dedent bool `json:"dedent"`
+
+	//Public is the visibility of the gist when the file does not say otherwise with a 'public' key, see GetPublic.
+	// Gists are secret by default.
+	Public bool `json:"public"`
+
+	//Redact masks secrets and configured patterns in the published content when the file does not say otherwise with
+	// a 'redact' key, see GetRedact. The masking itself is done by the caller, e.g. secrets.Scanner.Redact.
+	Redact bool `json:"redact"`
 }
 
 //ToGist is an accumulator method that performs multiple sub functions.
@@ -29,66 +50,154 @@ func (g *GistParser) ToGist() (*GistFile, error) {
 		return nil, err
 	}
 
-	if len(g.fileContents) == 0 {
-		err := g.Reader()
+	b, err := g.GetPublic()
+	if err != nil {
 		return nil, err
 	}
 
-	b, err := g.GetPublic()
+	files, err := g.GetFileBodies()
 	if err != nil {
 		return nil, err
 	}
 
-	gistFileBody, err := g.GetFileBody()
+	id, err := g.GetID()
 	if err != nil {
 		return nil, err
 	}
 
-	files := []GistFileBody{*gistFileBody}
 	return &GistFile{
+		ID:          id,
 		Description: description,
-		Files: files,
-		Public: b,
+		Files:       files,
+		Public:      b,
 	}, nil
 }
 
-//GetFileBody extracts the body of a gist from the file.
+//GetFileBody extracts the body of a gist from the file. The GOGIST header is removed from the body if GetStrip says
+// so.
 func (g *GistParser) GetFileBody() (*GistFileBody, error) {
 	if len(g.fileContents) == 0 {
-		err := g.Reader()
+		if err := g.Reader(); err != nil {
+			return nil, err
+		}
+	}
+
+	content := g.fileContents
+	header, err := g.parse()
+	if err != nil {
+		return nil, err
+	}
+	if header.Found() {
+		strip, err := g.GetStrip()
+		if err != nil {
+			return nil, err
+		}
+		if strip {
+			content = header.Strip(content)
+		}
+	}
+
+	filename, err := g.GetFilename()
+	if err != nil {
 		return nil, err
 	}
 
 	return &GistFileBody{
-		Content: string(g.fileContents),
-	},nil
+		Filename: filename,
+		Content:  string(content),
+	}, nil
+}
+
+//GetFileBodies returns the files of the gist. A file with regions, see Region, is published as one file per region,
+// otherwise it is published whole as returned by GetFileBody. A single region keeps the file name given by
+// GetFilename, several are named after it, see regionFilename.
+func (g *GistParser) GetFileBodies() ([]GistFileBody, error) {
+	if err := g.Reader(); err != nil {
+		return nil, err
+	}
+
+	regions, diagnostics := ParseRegions(g.Filepath, g.fileContents)
+	if len(diagnostics) > 0 {
+		return nil, diagnostics[0]
+	}
+	if len(regions) == 0 {
+		body, err := g.GetFileBody()
+		if err != nil {
+			return nil, err
+		}
+		return []GistFileBody{*body}, nil
+	}
+
+	filename, err := g.GetFilename()
+	if err != nil {
+		return nil, err
+	}
+	dedent, err := g.GetDedent()
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := make([]GistFileBody, 0, len(regions))
+	for _, r := range regions {
+		body := GistFileBody{Filename: filename, Content: r.Content}
+		if len(regions) > 1 {
+			body.Filename = regionFilename(filename, r.Name)
+		}
+		if dedent {
+			body.Content = Dedent(body.Content)
+		}
+		bodies = append(bodies, body)
+	}
+	return bodies, nil
 }
 
 // IsGistable checks to a see a certain file conforms to the GOGIST standard.
-// If the file does not contain the "GOGIST" label
+// If the file does not contain the "start GOGIST" and "end GOGIST" labels
 // in a comments section in the file. It is deemed ungistable meaning, gist will not create a gist for the user in that regard.
-// If no error is presented, one can assume that it is a gistable file.
+// If no error is presented, one can assume that it is a gistable file. A gistable file may still have an invalid
+// header, see Header.
 func (g *GistParser) IsGistable() error {
-	err := g.Reader()
+	header, err := g.parse()
 	if err != nil {
 		return err
 	}
+	if !header.Found() {
+		return header.Err()
+	}
+	return nil
+}
 
-	containsStart := bytes.Contains(bytes.ToLower(g.fileContents), bytes.ToLower([]byte("start GOGIST")))
-	if !containsStart {
-		return fmt.Errorf("is not a suitable GOGIST file. Add the following string 'start GOGIST' inside a comment section at the top of the file to mark it as a file gist can gist ;-)")
+//Header reads the file and parses its GOGIST header. An error is returned if the file cannot be read, does not
+// contain a complete header or the header has errors such as duplicate keys. Warnings, e.g. for unknown keys, are
+// available from the returned Header. The fields of a sidecar file, see SidecarSuffix, override those in the file.
+func (g *GistParser) Header() (*Header, error) {
+	header, err := g.parse()
+	if err != nil {
+		return nil, err
 	}
+	return header, header.Err()
+}
 
-	containsEnd := bytes.Contains(bytes.ToLower(g.fileContents), bytes.ToLower([]byte("end GOGIST")))
-	if !containsEnd {
-		return fmt.Errorf("is not a suitable GOGIST file. Add the following string 'end GOGIST' inside a comment section at the top of the file to mark it as a file gist can gist ;-). This should be after the start Gogist section")
+//parse reads the file and its sidecar, if any, and returns the merged header
+func (g *GistParser) parse() (*Header, error) {
+	err := g.Reader()
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	header := ParseHeader(g.Filepath, g.fileContents)
+	sidecar, err := readSidecar(g.Filepath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read sidecar -> %s", err)
+	}
+	if sidecar != nil {
+		header.merge(sidecar)
+	}
+	return header, nil
 }
 
-// GetAuthor returns the Author information. The author must precede the GOGIST label, and must contain all runes within the word
-// "AUTHOR". This is CASE sensitive
+// GetAuthor returns the Author information. The author must be within the GOGIST header, and its key must be exactly
+// "author". This is CASE insensitive
 //
 // This is the format shown below. Email is optional. Anything after newline carriage return is considered not part of the author label
 //
@@ -101,18 +210,15 @@ func (g *GistParser) IsGistable() error {
 //  returns I am some author <hereismy@email.com>
 //
 func (g *GistParser) GetAuthor() (string, error) {
-	lines, err := g.getGogistLines()
-	if err != nil {
-		return "", err
-	}
-	return g.getContent(lines, "author")
+	return g.getField("author")
 }
 
-// GetDescription returns the Author information. The Description must precede the GOGIST label,
-// and must contain all runes within the word
-// "Description". This is CASE sensitive
+// GetDescription returns the Description information. The Description must be within the GOGIST header,
+// and its key must be exactly
+// "description". This is CASE insensitive
 //
-// This is the format shown below. Email is optional. Anything after newline carriage return is considered not part of the Description label so ensure description is all in a single line.
+// This is the format shown below. Email is optional. A long description may continue onto the following lines as long
+// as they are indented further than the key, be quoted, or be a YAML style block scalar, see parseValue.
 //
 //	/** Start GOGIST
 //	Author: I am some author <hereismy@email.com>
@@ -123,18 +229,15 @@ func (g *GistParser) GetAuthor() (string, error) {
 //  returns Some awesome gist
 //
 func (g *GistParser) GetDescription() (string, error) {
-	lines, err := g.getGogistLines()
-	if err != nil {
-		return "", err
-	}
-	return g.getContent(lines, "description")
+	return g.getField("description")
 }
 
-// GetPublic returns the isPublic information. The Public must precede the GOGIST label,
-// and must contain all runes within the word
-// "AUTHOR". This is CASE insensitive
+// GetPublic returns the isPublic information. The Public must be within the GOGIST header,
+// and its key must be exactly
+// "public". This is CASE insensitive
 //
-// This is the format shown below. Email is optional. Public is a boolean variable that can either be true or false. Its default value is true
+// This is the format shown below. Email is optional. Public is a boolean variable that can either be true or false. Its default value is
+// GistParser.Public, so gists are secret unless asked otherwise
 //
 //	/** Start GOGIST
 //	Author: I am some author <hereismy@email.com>
@@ -145,65 +248,255 @@ func (g *GistParser) GetDescription() (string, error) {
 // returns true
 //
 func (g *GistParser) GetPublic() (bool, error) {
-	lines, err := g.getGogistLines()
+	return g.getBool("public", g.Public)
+}
+
+// GetStrip returns whether the GOGIST header should be removed from the published content. A 'strip' key in the
+// header takes precedence over GistParser.Strip, so a file can opt in or out regardless of the command line.
+//
+//	/** Start GOGIST
+//	Description: Some awesome gist
+//	Strip: true
+//	end gist
+//	*/
+//	returns true
+func (g *GistParser) GetStrip() (bool, error) {
+	return g.getBool("strip", g.Strip)
+}
+
+// GetFilename returns the name the file is published under. GistParser.Filename takes precedence over a 'filename'
+// key in the header, which in turn takes precedence over the base name of the local file. Names GitHub would reject
+// are an error, see ValidateFilename.
+//
+//	/** Start GOGIST
+//	Description: Some awesome gist
+//	Filename: main.go
+//	end gist
+//	*/
+//	returns main.go
+func (g *GistParser) GetFilename() (string, error) {
+	if g.Filename != "" {
+		return g.Filename, ValidateFilename(g.Filename)
+	}
+
+	header, err := g.Header()
 	if err != nil {
-		return false, err
+		return "", err
+	}
+	field, ok := header.Get("filename")
+	if !ok {
+		return filepath.Base(g.Filepath), nil
+	}
+	if err := ValidateFilename(field.Value); err != nil {
+		return "", Diagnostic{
+			File:     g.Filepath,
+			Line:     field.Line,
+			Column:   field.Column,
+			Severity: SeverityError,
+			Message:  err.Error(),
+		}
+	}
+	return field.Value, nil
+}
+
+// GetID returns the id of the gist the file was published as, written into the header by WriteID. It is empty if
+// the file has not been published.
+//
+//	/** Start GOGIST
+//	Description: Some awesome gist
+//	id: aa5a315d61ae9438b18d
+//	url: https://gist.github.com/aa5a315d61ae9438b18d
+//	end gist
+//	*/
+//	returns aa5a315d61ae9438b18d
+func (g *GistParser) GetID() (string, error) {
+	header, err := g.Header()
+	if err != nil {
+		return "", err
+	}
+	field, _ := header.Get("id")
+	return field.Value, nil
+}
+
+//WriteID records the id and url of the gist the file was published as in its header, or in its sidecar if it has
+// one, so the next push updates the gist rather than creating another. Existing 'id' and 'url' fields are replaced.
+// The file is rewritten atomically, and left untouched if nothing changes.
+func (g *GistParser) WriteID(id, url string) error {
+	fields := make([][2]string, 0, 2)
+	for _, field := range [][2]string{{"id", id}, {"url", url}} {
+		if field[1] != "" {
+			fields = append(fields, field)
+		}
+	}
+	return g.setFields(fields)
+}
+
+//setFields sets the given header fields, in order, in the sidecar of the file if it has one or in the file itself,
+// see Header.SetField.
+func (g *GistParser) setFields(fields [][2]string) error {
+	target := g.Filepath
+	sidecar := g.Filepath + SidecarSuffix
+	if _, err := os.Stat(sidecar); err == nil {
+		target = sidecar
+	}
+
+	original, err := ioutil.ReadFile(target)
+	if err != nil {
+		return fmt.Errorf("file may not exist -> %s", err)
+	}
+
+	data := original
+	for _, field := range fields {
+		header := ParseHeader(target, data)
+		if target == sidecar {
+			header = ParseSidecar(target, data)
+		}
+		if data, err = header.SetField(data, field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	if bytes.Equal(data, original) {
+		return nil
+	}
+	if err := writeFileAtomic(target, data); err != nil {
+		return fmt.Errorf("could not write %s -> %s", target, err)
+	}
+	g.fileContents = nil
+	return nil
+}
+
+// GetDedent returns whether the indentation shared by the lines of a region is removed before it is published. A
+// 'dedent' key in the header takes precedence over GistParser.Dedent.
+//
+//	/** Start GOGIST
+//	Description: Some awesome gist
+//	Dedent: true
+//	end gist
+//	*/
+//	returns true
+func (g *GistParser) GetDedent() (bool, error) {
+	return g.getBool("dedent", g.Dedent)
+}
+
+// GetRedact returns whether secrets and configured patterns are masked in the published content, e.g. for a
+// configuration example. A 'redact' key in the header takes precedence over GistParser.Redact.
+//
+//	/** Start GOGIST
+//	Description: Sample configuration
+//	Redact: true
+//	end gist
+//	*/
+//	returns true
+func (g *GistParser) GetRedact() (bool, error) {
+	return g.getBool("redact", g.Redact)
+}
+
+//The values of the 'encrypt' key with a meaning of their own, any other names an encryption profile
+const (
+	//EncryptDefault is the profile of 'encrypt: true'
+	EncryptDefault = "default"
+
+	//EncryptPassphrase encrypts with a key derived from a passphrase rather than to the recipients of a profile
+	EncryptPassphrase = "passphrase"
+)
+
+// GetEncrypt returns how the published content is encrypted: not at all if it returns "", with a passphrase for
+// EncryptPassphrase, and to the recipients of the named profile otherwise, EncryptDefault for 'encrypt: true'.
+//
+//	/** Start GOGIST
+//	Description: Deploy notes
+//	Public: false
+//	Encrypt: team
+//	end gist
+//	*/
+//	returns team
+func (g *GistParser) GetEncrypt() (string, error) {
+	header, err := g.Header()
+	if err != nil {
+		return "", err
+	}
+	field, ok := header.Get("encrypt")
+	if !ok {
+		return "", nil
+	}
+	if b, err := strconv.ParseBool(field.Value); err == nil {
+		if b {
+			return EncryptDefault, nil
+		}
+		return "", nil
 	}
-	content, err := g.getContent(lines, "public")
+	return field.Value, nil
+}
+
+//getBool returns the boolean value of key in the GOGIST header, or def if the key is absent.
+func (g *GistParser) getBool(key string, def bool) (bool, error) {
+	header, err := g.Header()
 	if err != nil {
-		return true, nil
+		return false, err
 	}
-	b, err := strconv.ParseBool(content)
+	field, ok := header.Get(key)
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(field.Value)
 	if err != nil {
-		return false, fmt.Errorf("couldnt parse string to bool -> %v", err)
+		return false, Diagnostic{
+			File:     g.Filepath,
+			Line:     field.Line,
+			Column:   field.Column,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("couldnt parse %s value %q to bool", key, field.Value),
+		}
 	}
 	return b, nil
 }
 
+//getField returns the value of key in the GOGIST header, or an error if the header is invalid or lacks the key.
+func (g *GistParser) getField(key string) (string, error) {
+	header, err := g.Header()
+	if err != nil {
+		return "", err
+	}
+	field, ok := header.Get(key)
+	if !ok {
+		return "", fmt.Errorf("%s does not exist", key)
+	}
+	return field.Value, nil
+}
+
 //getGogistLines returns the lines encapsulated by the 'start gist' and the'end gist' labels. This is where all the important gist metadata is found.
 func (g *GistParser) getGogistLines() ([]string, error) {
-	err := g.IsGistable()
+	header, err := g.Header()
 	if err != nil {
 		return nil, fmt.Errorf("could not determine if file has 'start gist' and the'end gist' labels -> %s", err.Error())
 	}
+	if header.Source != SourceComment || !header.inFile() {
+		return nil, fmt.Errorf("the GOGIST header is not written in a comment section")
+	}
 
-	buffer := bytes.NewBuffer(g.fileContents)
-	documentContents := strings.SplitAfter(buffer.String(), "\n")
-
-	startIndex := -1
-	endIndex := -1
+	lines := splitLines(string(g.fileContents))
+	comments := scanComments(lines, header.syntax)
+	documentContents := lines[header.StartLine-1 : header.EndLine]
 	for i := range documentContents {
-		documentContents[i] = strings.Trim(documentContents[i], " \r\n")
-		if strings.Contains(  strings.ToLower(documentContents[i]), strings.ToLower("start gist"))  {
-			startIndex = i
-		}
-		if strings.Contains(strings.ToLower(documentContents[i]), strings.ToLower("end gist"))  {
-			endIndex = i
-			break
+		//lines that are not comments keep their indentation as it decides whether they continue a value
+		if comments[header.StartLine-1+i].isComment {
+			documentContents[i] = strings.Trim(documentContents[i], " \t")
+		} else {
+			documentContents[i] = strings.TrimRight(documentContents[i], " \t")
 		}
 	}
-
-	return documentContents[startIndex:endIndex+1], nil
+	return documentContents, nil
 }
 
 //getContent takes in the gist section obtained after running getGogistLines, and obtaining the exact metadata section. key represents a  key in a key-value pair. e.g. author or description are valid keys
 func (g *GistParser) getContent(s []string, key string) (string, error) {
-	var location = -1
-	for i, v := range s {
-		if strings.Contains(strings.ToLower(v), strings.ToLower(key)) {
-			location = i
-			break
-		}
-	}
-	if location > 0 {
-		totalString := strings.TrimSpace(s[location])
-		replaced := strings.Replace(strings.ToLower(totalString), strings.ToLower(key)+":", "", -1)
-		lenreplaced := len(replaced)
-		finstring := totalString[(len(totalString) - lenreplaced):]
-		trim := strings.Trim(finstring, " ")
-		return trim, nil
+	_, syntax := syntaxFor(g.Filepath)
+	field, ok := parseHeader(g.Filepath, s, syntax).Get(key)
+	if !ok {
+		return "", fmt.Errorf("%s does not exist", key)
 	}
-	return "", fmt.Errorf(key + " does not exist")
+	return field.Value, nil
 }
 
 
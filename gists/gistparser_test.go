@@ -2,6 +2,8 @@ package gists
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -17,11 +19,11 @@ var filepathf = "testdata/test-f.f"
 var filepathgo = "testdata/test-go.go"
 var filepathrandom = "testdata/test-random.rand"
 
-var gogistsectiona, _ = (&GistParser{filepatha, nil}).getGogistLines()
-var gogistsectionb, _ = (&GistParser{filepathb, nil}).getGogistLines()
-var gogistsectionc, _ = (&GistParser{filepathc, nil}).getGogistLines()
-var gogistsectiongo, _ = (&GistParser{filepathgo, nil}).getGogistLines()
-var gogistsectionrand, _ = (&GistParser{filepathrandom, nil}).getGogistLines()
+var gogistsectiona, _ = (&GistParser{Filepath: filepatha}).getGogistLines()
+var gogistsectionb, _ = (&GistParser{Filepath: filepathb}).getGogistLines()
+var gogistsectionc, _ = (&GistParser{Filepath: filepathc}).getGogistLines()
+var gogistsectiongo, _ = (&GistParser{Filepath: filepathgo}).getGogistLines()
+var gogistsectionrand, _ = (&GistParser{Filepath: filepathrandom}).getGogistLines()
 
 func readFile(path string) string {
 	data, err := ioutil.ReadFile(path)
@@ -44,26 +46,26 @@ func TestGistParser_ToGist(t *testing.T) {
 	}{
 		{"bad-file", fields{Filepath:badfilepath, fileContents: nil}, nil, true},
 		{"samplefile-a", fields{Filepath:filepatha, fileContents: nil}, nil, true},
-		//This will only get the first line as the attributes should not be multilined
+		//The description continues onto the lines indented further than its key
 		{"samplefile-b", fields{Filepath:filepathb, fileContents: nil}, &GistFile{
-			Description: `the following program will calculate the constant e-2 to about`,
-			Public: true,
-			Files: []GistFileBody{{ readFile(filepathb) }},
+			Description: `the following program will calculate the constant e-2 to about 4000 decimal digits, and print it 50 characters to the line in groups of 5 characters.`,
+			Public: false,
+			Files: []GistFileBody{{Filename: filepath.Base(filepathb), Content: readFile(filepathb)}},
 		}, false},
 		{"samplefile-c", fields{Filepath:filepathc, fileContents: nil}, &GistFile{
 			Description: `How to create random vars in C`,
 			Public: true,
-			Files: []GistFileBody{{ readFile(filepathc) }},
+			Files: []GistFileBody{{Filename: "c_rand.c", Content: readFile(filepathc)}},
 		}, false},
 		{"samplefile-go", fields{Filepath:filepathgo, fileContents: nil}, &GistFile{
 			Description: `How to create a server in Go`,
 			Public: false,
-			Files: []GistFileBody{{ readFile(filepathgo) }},
+			Files: []GistFileBody{{Filename: filepath.Base(filepathgo), Content: readFile(filepathgo)}},
 		}, false},
 		{"samplefile-random", fields{Filepath:filepathrandom, fileContents: nil}, &GistFile{
 			Description: "_fnsofld",
-			Public: true,
-			Files: []GistFileBody{{ readFile(filepathrandom) }},
+			Public: false,
+			Files: []GistFileBody{{Filename: filepath.Base(filepathrandom), Content: readFile(filepathrandom)}},
 		}, false},
 	}
 	for _, tt := range tests {
@@ -297,7 +299,7 @@ func TestGistParser_getContent(t *testing.T) {
 			want: "", wantErr:true},
 		{name: "should correctly obtain definition contents", fields:fields{filepathb, nil}, args:args{gogistsectionb,
 			"description"},
-			want:"the following program will calculate the constant e-2 to about", wantErr:false},
+			want:"the following program will calculate the constant e-2 to about 4000 decimal digits, and print it 50 characters to the line in groups of 5 characters.", wantErr:false},
 		//{name: "xxx-xxx", fields:fields{filepatha, nil}, args:args{gogistsectionc, "definition"},
 		//	want:"This gist has no end", wantErr:false},
 		//{name: "xxx-xxx", fields:fields{filepatha, nil}, args:args{gogistsectiongo, "definition"},
@@ -347,3 +349,91 @@ func TestGistParser_Reader(t *testing.T) {
 		})
 	}
 }
+
+func TestGistParser_GetFilename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filename")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	plain := write("scratch_v3_final.go", "// start gist\n// description: d\n// end gist\n")
+	named := write("scratch_v4.go", "// start gist\n// filename: main.go\n// end gist\n")
+	invalid := write("scratch_v5.go", "// start gist\n// filename: cmd/main.go\n// end gist\n")
+
+	tests := []struct {
+		name     string
+		path     string
+		override string
+		want     string
+		wantErr  bool
+	}{
+		{"base name", plain, "", "scratch_v3_final.go", false},
+		{"header key", named, "", "main.go", false},
+		{"override wins", named, "server.go", "server.go", false},
+		{"invalid key", invalid, "", "", true},
+		{"invalid override", plain, "a/b.go", "a/b.go", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &GistParser{Filepath: tt.path, Filename: tt.override}
+			got, err := g.GetFilename()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GistParser.GetFilename() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GistParser.GetFilename() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGistParser_GetFileBodies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "regions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scratch.go")
+	content := "// start gist\n// filename: main.go\n// end gist\npackage main\n\n" +
+		"type s struct{}\n\n// gist:region handler\nfunc (s) handler() {\n\tprintln()\n}\n// gist:endregion\n\n" +
+		"func main() {\n\t// gist:region loop\n\tfor {\n\t}\n\t// gist:endregion\n}\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		dedent bool
+		want   []GistFileBody
+	}{
+		{"regions", false, []GistFileBody{
+			{Filename: "main-handler.go", Content: "func (s) handler() {\n\tprintln()\n}\n"},
+			{Filename: "main-loop.go", Content: "\tfor {\n\t}\n"},
+		}},
+		{"dedented", true, []GistFileBody{
+			{Filename: "main-handler.go", Content: "func (s) handler() {\n\tprintln()\n}\n"},
+			{Filename: "main-loop.go", Content: "for {\n}\n"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &GistParser{Filepath: path, Dedent: tt.dedent}
+			got, err := g.GetFileBodies()
+			if err != nil {
+				t.Fatalf("GistParser.GetFileBodies() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GistParser.GetFileBodies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
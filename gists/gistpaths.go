@@ -12,4 +12,15 @@ const (
 
 	//EndpointGistCreateMethod is the appropriate HTTP method for creating a gist
 	EndpointGistCreateMethod = http.MethodPost
-)
\ No newline at end of file
+
+	//EndpointGistUpdateMethod is the appropriate HTTP method for updating a gist at its GistURL
+	EndpointGistUpdateMethod = http.MethodPatch
+
+	//EndpointGistDeleteMethod is the appropriate HTTP method for deleting a gist at its GistURL
+	EndpointGistDeleteMethod = http.MethodDelete
+)
+
+//GistURL returns the API URL of the gist with the given id
+func GistURL(id string) string {
+	return EndpointBase + EndpointGistCreate + "/" + id
+}
@@ -2,27 +2,70 @@ package gists
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/martinomburajr/gist/auth"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
 )
 
 
 //GistFile represents an application facing Gist that a user can create. Typically populated through the use of flags. It contains the barebones for what a gist on GitHub may be.
 // A GistFile implements a cruder interface and can perform all basic operations.
 type GistFile struct {
+	//ID identifies the remote gist, it is empty until the gist has been created
+	ID          string         `json:"id,omitempty"`
 	Description string         `json:"description"`
 	Public      bool           `json:"public"`
-	Files       []GistFileBody `json:"file"`
+	Files       []GistFileBody `json:"files"`
+
+	//URL and Revision are the address of the gist on gist.github.com and the SHA of its latest revision, they are only
+	// set on gists returned by Retrieve or List
+	URL      string `json:"html_url,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+//MarshalJSON encodes the gist the way the GitHub API expects it, with the files keyed by their file names. Files
+// without a name are given GitHub's own default of gistfileN.txt.
+//https://developer.github.com/v3/gists/#create-a-gist
+func (g GistFile) MarshalJSON() ([]byte, error) {
+	files := make(map[string]GistFileBody, len(g.Files))
+	for i, f := range g.Files {
+		name := f.Filename
+		if name == "" {
+			name = fmt.Sprintf("gistfile%d.txt", i+1)
+		}
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("the gist has more than one file named %q", name)
+		}
+		files[name] = f
+	}
+
+	return json.Marshal(struct {
+		Description string                  `json:"description"`
+		Public      bool                    `json:"public"`
+		Files       map[string]GistFileBody `json:"files"`
+	}{
+		Description: g.Description,
+		Public:      g.Public,
+		Files:       files,
+	})
 }
 
 //Delete Removes the remote Gist
 func (g *GistFile) Delete(id string) (*http.Response, error) {
-	urll := fmt.Sprintf("/gists/%s", id)
-	req, err := http.NewRequest(http.MethodDelete, urll, nil)
+	return g.DeleteContext(context.Background(), id)
+}
+
+//DeleteContext is Delete with a context, see auth.WithToken
+func (g *GistFile) DeleteContext(ctx context.Context, id string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, EndpointGistDeleteMethod, GistURL(id), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -34,23 +77,90 @@ func (g *GistFile) Delete(id string) (*http.Response, error) {
 	return resp, nil
 }
 
-//Update updates a given remote Gist
-func (g *GistFile) Update(interface{}) (*http.Response, error) {
-	panic("implement me")
+//ErrMadePublic is returned by Update for a secret gist that newObj would make public, which GitHub does not allow
+var ErrMadePublic = errors.New("a secret gist cannot be made public, GitHub does not allow it; publish the file " +
+	"as a new gist or set 'public: false'")
+
+//ErrMadeSecret is returned by Update for a public gist that newObj would make secret, which GitHub does not allow
+var ErrMadeSecret = errors.New("a public gist cannot be made secret, GitHub does not allow it; delete the gist and " +
+	"publish the file again or set 'public: true'")
+
+//Update replaces the description and files of the remote gist identified by g.ID with those of newObj, which must
+// be a GistFile or a pointer to one. Files that only exist remotely are left in place. g takes on the contents of
+// newObj once GitHub accepts the update. GitHub cannot change the visibility of a gist, so an update that would is
+// refused with ErrMadePublic or ErrMadeSecret before anything is sent. The visibility of the remote gist is that of g
+// if g was retrieved or listed, i.e. has a URL, otherwise it is fetched on its own. If it cannot be fetched the update
+// is sent anyway, so the response is GitHub's answer to the update itself.
+//https://developer.github.com/v3/gists/#edit-a-gist
+func (g *GistFile) Update(newObj interface{}) (*http.Response, error) {
+	return g.UpdateContext(context.Background(), newObj)
+}
+
+//UpdateContext is Update with a context, see auth.WithToken
+func (g *GistFile) UpdateContext(ctx context.Context, newObj interface{}) (*http.Response, error) {
+	var updated GistFile
+	switch obj := newObj.(type) {
+	case *GistFile:
+		updated = *obj
+	case GistFile:
+		updated = obj
+	default:
+		return nil, fmt.Errorf("cannot update a gist with a %T", newObj)
+	}
+	if g.ID == "" {
+		return nil, fmt.Errorf("cannot update a gist without an id")
+	}
+
+	public, err := g.Public, error(nil)
+	if g.URL == "" {
+		public, err = visibility(ctx, g.ID)
+	}
+	switch {
+	case err != nil:
+	case updated.Public && !public:
+		return nil, ErrMadePublic
+	case !updated.Public && public:
+		return nil, ErrMadeSecret
+	}
+
+	data, err := json.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, EndpointGistUpdateMethod, GistURL(g.ID), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := auth.Session.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		updated.ID = g.ID
+		*g = updated
+	}
+	return resp, nil
 }
 
 // Create ensures that given a GistFile in its basic form,
 // create a gist on Github that takes the contents of the Files,
 // description and whether or not it is public.
 func (g *GistFile) Create() (*http.Response, error) {
+	return g.CreateContext(context.Background())
+}
+
+//CreateContext is Create with a context, see auth.WithToken
+func (g *GistFile) CreateContext(ctx context.Context) (*http.Response, error) {
 	data, err := json.Marshal(g)
 	if err != nil {
 		return nil, err
 	}
 
-	urll := EndpointBase+"/gists"
+	urll := EndpointBase + EndpointGistCreate
 
-	req, err := http.NewRequest(http.MethodPost, urll,  bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, EndpointGistCreateMethod, urll,  bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -62,10 +172,27 @@ func (g *GistFile) Create() (*http.Response, error) {
 	return resp, nil
 }
 
-// Retrieve obtains a gist given the remote gist id
+// Retrieve obtains a gist given the remote gist id. Responses are revalidated with their ETag when auth.Session.Client
+// uses a cache.Transport, so an unchanged gist is served from the local cache.
 //https://developer.github.com/v3/gists/#get-a-single-gist
-func  (g *GistFile) Retrieve(id string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, "/gists/"+id, nil)
+func (g *GistFile) Retrieve(id string) (*http.Response, error) {
+	return g.RetrieveContext(context.Background(), id)
+}
+
+//RetrieveContext is Retrieve with a context, see auth.WithToken
+func (g *GistFile) RetrieveContext(ctx context.Context, id string) (*http.Response, error) {
+	return g.retrieve(ctx, id, GistURL(id))
+}
+
+// RetrieveRevision obtains a gist as it was at the given revision, see GistFile.Revision.
+//https://developer.github.com/v3/gists/#get-a-specific-revision-of-a-gist
+func (g *GistFile) RetrieveRevision(id, revision string) (*http.Response, error) {
+	return g.retrieve(context.Background(), id, GistURL(id)+"/"+revision)
+}
+
+//retrieve obtains the gist id from url
+func (g *GistFile) retrieve(ctx context.Context, id, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -81,22 +208,193 @@ func  (g *GistFile) Retrieve(id string) (*http.Response, error) {
 		return resp, err
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("could not retrieve gist %s -> %s", id, resp.Status)
+	}
+
 	var gf httpGistResponse
-	err = json.Unmarshal(data, gf)
+	err = json.Unmarshal(data, &gf)
 	if err != nil {
 		return resp, err
 	}
 
+	g.fromResponse(&gf)
+	for i, f := range g.Files {
+		file := gf.Files[f.Filename]
+		if !file.Truncated {
+			continue
+		}
+		content, err := fetchRaw(ctx, file.RawURL)
+		if err != nil {
+			return resp, fmt.Errorf("could not retrieve %s of gist %s -> %s", f.Filename, id, err)
+		}
+		g.Files[i].Content = content
+	}
+
+	return resp, nil
+}
+
+//visibility returns whether the gist id is public, without fetching the content of its truncated files as Retrieve
+// does
+func visibility(ctx context.Context, id string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, GistURL(id), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := auth.Session.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("could not retrieve gist %s -> %s", id, resp.Status)
+	}
+	var gf struct {
+		Public bool `json:"public"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&gf); err != nil {
+		return false, err
+	}
+	return gf.Public, nil
+}
+
+//fromResponse sets g from a gist returned by the GitHub API, with its files sorted by name
+func (g *GistFile) fromResponse(gf *httpGistResponse) {
+	filenames := make([]string, 0, len(gf.Files))
+	for filename := range gf.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	g.ID = gf.ID
+	g.URL = gf.HTMLURL
 	g.Description = gf.Description
-	g.Files[0].Content = gf.Files.Gists[0].Content
 	g.Public = gf.Public
+	g.Revision = ""
+	if len(gf.History) > 0 {
+		g.Revision = gf.History[0].Version
+	}
+	g.Files = make([]GistFileBody, 0, len(filenames))
+	for _, filename := range filenames {
+		g.Files = append(g.Files, GistFileBody{Filename: filename, Content: gf.Files[filename].Content})
+	}
+}
 
-	return resp, nil
+//fetchRaw returns the content of a file too large to be included in the API response
+func fetchRaw(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := auth.Session.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	return string(data), err
 }
 
-//GistFileBody holds the contents of a gist file as a string
+//List returns every gist of the authenticated user, following GitHub's pagination. The files of the gists are named
+// but their content is not included, see Retrieve.
+//https://developer.github.com/v3/gists/#list-a-users-gists
+func List() ([]*GistFile, error) {
+	return ListContext(context.Background())
+}
+
+//ListContext is List with a context, see auth.WithToken
+func ListContext(ctx context.Context) ([]*GistFile, error) {
+	return list(ctx, EndpointBase+EndpointGistCreate+"?per_page=100")
+}
+
+//list returns the gists listed from url onwards, following GitHub's pagination
+func list(ctx context.Context, next string) ([]*GistFile, error) {
+	list := make([]*GistFile, 0)
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := auth.Session.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("could not list gists -> %s", resp.Status)
+		}
+
+		var page []httpGistResponse
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		for i := range page {
+			g := &GistFile{}
+			g.fromResponse(&page[i])
+			for j := range g.Files {
+				g.Files[j].Content = ""
+			}
+			list = append(list, g)
+		}
+		next = nextPage(resp.Header.Get("Link"))
+	}
+	return list, nil
+}
+
+//nextPage returns the URL of the next page from a Link header, or "" on the last page
+func nextPage(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		sections := strings.Split(part, ";")
+		if len(sections) < 2 {
+			continue
+		}
+		for _, param := range sections[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(sections[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+//GistFileBody holds the contents of a gist file as a string. Filename is the name the file is published under, it
+// is encoded as the key of the file by GistFile.MarshalJSON.
 type GistFileBody struct {
-	Content string `json:"content"`
+	Filename string `json:"-"`
+	Content  string `json:"content"`
+}
+
+//MaxFilenameLength is the longest file name, in bytes, accepted by ValidateFilename
+const MaxFilenameLength = 255
+
+//ValidateFilename returns an error if GitHub would reject name as the name of a gist file. A name may not be empty,
+// be . or .., contain a path separator or control characters, nor begin or end with white space.
+func ValidateFilename(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("filename is empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("filename %q is not allowed", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("filename %q may not contain / or \\", name)
+	case strings.TrimSpace(name) != name:
+		return fmt.Errorf("filename %q may not begin or end with white space", name)
+	case len(name) > MaxFilenameLength:
+		return fmt.Errorf("filename is longer than %d bytes", MaxFilenameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("filename %q may not contain control characters", name)
+		}
+	}
+	return nil
 }
 
 //GistOwner refers to a information returned  regarding the owner of a gist. See the GitHub API docs
@@ -153,9 +451,7 @@ type httpGistResponse struct {
 	GitPullURL string `json:"git_pull_url"`
 	GitPushURL string `json:"git_push_url"`
 	HTMLURL    string `json:"html_url"`
-	Files      struct {
-		Gists []httpGistFileResponse
-	} `json:"files"`
+	Files      map[string]httpGistFileResponse `json:"files"`
 	Public      bool        `json:"public"`
 	CreatedAt   time.Time   `json:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at"`
@@ -1,18 +1,18 @@
 package gists
 
 import (
-	"bytes"
 	"encoding/json"
-	"log"
+	"github.com/martinomburajr/gist/auth"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 )
 
 var DummyGistFile1 =  GistFile{
 	Description: "",
-	Files: []GistFileBody{ {Content: "test-a.a"}},
+	Files: []GistFileBody{ {Filename: "main.go", Content: "test-a.a"}},
 	Public: false,
 }
 
@@ -46,7 +46,7 @@ func TestGistFile_Delete(t *testing.T) {
 
 func TestGistFile_Update(t *testing.T) {
 	type args struct {
-		in0 interface{}
+		newObj interface{}
 	}
 	tests := []struct {
 		name    string
@@ -55,11 +55,12 @@ func TestGistFile_Update(t *testing.T) {
 		want    *http.Response
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{"not a gist", &GistFile{ID: "aa5a315d61ae9438b18d"}, args{"description"}, nil, true},
+		{"no id", &GistFile{}, args{&GistFile{}}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.g.Update(tt.args.in0)
+			got, err := tt.g.Update(tt.args.newObj)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GistFile.Update() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -71,45 +72,193 @@ func TestGistFile_Update(t *testing.T) {
 	}
 }
 
+//roundTripFunc lets a function stand in for auth.Session.Client's transport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
 func TestGistFile_Create(t *testing.T) {
-	data, err := json.Marshal(DummyGistFile1)
+	var got map[string]interface{}
+	var gotURL string
+	client := auth.Session.Client
+	defer func() { auth.Session.Client = client }()
+	auth.Session.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		gotURL = r.URL.String()
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			return nil, err
+		}
+		recorder := httptest.NewRecorder()
+		recorder.WriteHeader(http.StatusCreated)
+		return recorder.Result(), nil
+	})}
+
+	resp, err := DummyGistFile1.Create()
 	if err != nil {
-		t.Error(err)
+		t.Fatalf("GistFile.Create() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("GistFile.Create() status = %v, want %v", resp.StatusCode, http.StatusCreated)
+	}
+	if want := EndpointBase + EndpointGistCreate; gotURL != want {
+		t.Errorf("GistFile.Create() url = %v, want %v", gotURL, want)
+	}
+	want := map[string]interface{}{
+		"description": "",
+		"public":      false,
+		"files":       map[string]interface{}{"main.go": map[string]interface{}{"content": "test-a.a"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GistFile.Create() sent %v, want %v", got, want)
 	}
+}
 
-	mux := http.DefaultServeMux
-	req, err := http.NewRequest(http.MethodPost, "/gists",  bytes.NewReader(data))
-	if err != nil {
-		log.Fatal("")
-	}
-	recorder := httptest.NewRecorder()
-	mux.ServeHTTP(recorder, req)
-
-	if status := recorder.Code; recorder.Code > 399 {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusOK)
-	}
-
-	//tests := []struct {
-	//	name    string
-	//	g       *GistFile
-	//	want    *http.Response
-	//	wantErr bool
-	//}{
-	//	// TODO: Add test cases.
-	//}
-	//for _, tt := range tests {
-	//	t.Run(tt.name, func(t *testing.T) {
-	//		got, err := tt.g.Create()
-	//		if (err != nil) != tt.wantErr {
-	//			t.Errorf("GistFile.Create() error = %v, wantErr %v", err, tt.wantErr)
-	//			return
-	//		}
-	//		if !reflect.DeepEqual(got, tt.want) {
-	//			t.Errorf("GistFile.Create() = %v, want %v", got, tt.want)
-	//		}
-	//	})
-	//}
+//serveGist has auth.Session.Client answer every GET with a gist of the given visibility, and every other request
+// with 200 OK after recording its method and URL. It returns the function restoring the client.
+func serveGist(public bool, gotMethod, gotURL *string) func() {
+	client := auth.Session.Client
+	auth.Session.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		if r.Method == http.MethodGet {
+			json.NewEncoder(recorder).Encode(map[string]interface{}{"id": "aa5a315d61ae9438b18d", "public": public})
+			return recorder.Result(), nil
+		}
+		*gotMethod, *gotURL = r.Method, r.URL.String()
+		recorder.WriteHeader(http.StatusOK)
+		return recorder.Result(), nil
+	})}
+	return func() { auth.Session.Client = client }
+}
+
+func TestGistFile_UpdateRequest(t *testing.T) {
+	var gotMethod, gotURL string
+	defer serveGist(false, &gotMethod, &gotURL)()
+
+	g := &GistFile{ID: "aa5a315d61ae9438b18d"}
+	if _, err := g.Update(DummyGistFile1); err != nil {
+		t.Fatalf("GistFile.Update() error = %v", err)
+	}
+	if gotMethod != http.MethodPatch || gotURL != GistURL("aa5a315d61ae9438b18d") {
+		t.Errorf("GistFile.Update() sent %s %s", gotMethod, gotURL)
+	}
+	want := DummyGistFile1
+	want.ID = "aa5a315d61ae9438b18d"
+	if !reflect.DeepEqual(*g, want) {
+		t.Errorf("GistFile.Update() left %+v, want %+v", *g, want)
+	}
+}
+
+func TestGistFile_UpdateVisibility(t *testing.T) {
+	tests := []struct {
+		name      string
+		remote    bool
+		public    bool
+		retrieved bool
+		getStatus int
+		wantGets  int
+		wantErr   error
+	}{
+		{"secret", false, false, false, http.StatusOK, 1, nil},
+		{"public", true, true, false, http.StatusOK, 1, nil},
+		{"made public", false, true, false, http.StatusOK, 1, ErrMadePublic},
+		{"made secret", true, false, false, http.StatusOK, 1, ErrMadeSecret},
+		{"retrieved", true, true, true, http.StatusOK, 0, nil},
+		{"retrieved made secret", true, false, true, http.StatusOK, 0, ErrMadeSecret},
+		{"visibility unknown", true, false, false, http.StatusBadGateway, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gets, sent := 0, false
+			client := auth.Session.Client
+			defer func() { auth.Session.Client = client }()
+			auth.Session.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				recorder := httptest.NewRecorder()
+				if r.Method == http.MethodGet {
+					gets++
+					recorder.WriteHeader(tt.getStatus)
+					json.NewEncoder(recorder).Encode(map[string]interface{}{"id": "aa5a315d61ae9438b18d",
+						"public": tt.remote, "files": map[string]interface{}{"a.go": map[string]interface{}{
+							"truncated": true, "raw_url": "https://gist.githubusercontent.com/a.go"}}})
+					return recorder.Result(), nil
+				}
+				sent = true
+				recorder.WriteHeader(http.StatusNotFound)
+				return recorder.Result(), nil
+			})}
+
+			g := &GistFile{ID: "aa5a315d61ae9438b18d"}
+			if tt.retrieved {
+				g.Public, g.URL = tt.remote, "https://gist.github.com/aa5a315d61ae9438b18d"
+			}
+			updated := DummyGistFile1
+			updated.Public = tt.public
+			resp, err := g.Update(updated)
+			if err != tt.wantErr {
+				t.Errorf("GistFile.Update() error = %v, want %v", err, tt.wantErr)
+			}
+			if sent != (tt.wantErr == nil) {
+				t.Errorf("GistFile.Update() sent the update = %v", sent)
+			}
+			if sent && resp.StatusCode != http.StatusNotFound {
+				t.Errorf("GistFile.Update() status = %d, want the status of the update", resp.StatusCode)
+			}
+			if gets != tt.wantGets {
+				t.Errorf("GistFile.Update() sent %d GETs, want %d", gets, tt.wantGets)
+			}
+		})
+	}
+}
+
+func TestGistFile_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []GistFileBody
+		want    string
+		wantErr bool
+	}{
+		{"named", []GistFileBody{{Filename: "a.go", Content: "a"}, {Filename: "b.go", Content: "b"}},
+			`{"description":"","public":false,"files":{"a.go":{"content":"a"},"b.go":{"content":"b"}}}`, false},
+		{"unnamed", []GistFileBody{{Content: "a"}},
+			`{"description":"","public":false,"files":{"gistfile1.txt":{"content":"a"}}}`, false},
+		{"duplicate", []GistFileBody{{Filename: "a.go"}, {Filename: "a.go"}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(&GistFile{Files: tt.files})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GistFile.MarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("GistFile.MarshalJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"main.go", false},
+		{"scratch v3 final.go", false},
+		{"", true},
+		{"  ", true},
+		{"..", true},
+		{"dir/main.go", true},
+		{`dir\main.go`, true},
+		{" main.go", true},
+		{"main\x00.go", true},
+		{strings.Repeat("a", MaxFilenameLength+1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateFilename(tt.name); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFilename() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
 }
 
 func TestGistFile_Retrieve(t *testing.T) {
@@ -138,3 +287,24 @@ func TestGistFile_Retrieve(t *testing.T) {
 		})
 	}
 }
+
+func Test_nextPage(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"no link", "", ""},
+		{"next and last", `<https://api.github.com/gists?page=2>; rel="next", <https://api.github.com/gists?page=5>; rel="last"`,
+			"https://api.github.com/gists?page=2"},
+		{"last page", `<https://api.github.com/gists?page=1>; rel="first", <https://api.github.com/gists?page=4>; rel="prev"`,
+			""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextPage(tt.link); got != tt.want {
+				t.Errorf("nextPage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
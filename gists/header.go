@@ -0,0 +1,374 @@
+package gists
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//Severity indicates whether a Diagnostic prevents a file from being gisted
+type Severity int
+
+const (
+	//SeverityError diagnostics make the header invalid
+	SeverityError Severity = iota
+
+	//SeverityWarning diagnostics are reported but the header is still used
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+//MarshalText implements encoding.TextMarshaler so diagnostics read well in JSON reports
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+//Diagnostic is a problem found while parsing a GOGIST header. Line and Column start at 1, a Line of 0 refers to the
+// file as a whole.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+//Error formats the diagnostic as file:line:column: severity: message
+func (d Diagnostic) Error() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+//KnownKeys are the keys understood in a GOGIST header. Any other key is kept but produces a warning.
+var KnownKeys = []string{"author", "dedent", "description", "encrypt", "filename", "id", "public", "redact", "strip",
+	"url"}
+
+//Field is a single 'key: value' entry of a GOGIST header. Key is always lower case. A field whose value continues
+// onto further lines ends on EndLine.
+type Field struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Line    int    `json:"line"`
+	EndLine int    `json:"end_line"`
+	Column  int    `json:"column"`
+}
+
+//Header is the parsed GOGIST block of a file. It begins with a comment line reading 'start gist' (or 'start gogist')
+// and finishes with one reading 'end gist' (or 'end gogist'), both case insensitive. The comment lines in between
+// hold 'key: value' fields, keys are matched exactly and case insensitively. Values may be quoted, span several lines
+// or be block scalars, see parseValue.
+//
+//	/* Start GOGIST
+//	   Author: I am some author <hereismy@email.com>
+//	   Description: Some awesome gist
+//	   Public: true
+//	   end gist
+//	*/
+//
+//The same fields may instead come from YAML or TOML front matter, see parseFrontMatter, or from a sidecar file, see
+// ParseSidecar.
+type Header struct {
+	File string
+
+	//Source is where the fields were found, one of the Source constants
+	Source string
+
+	//Language is the language the file was recognised as from its name, empty if it is unknown
+	Language string
+
+	//StartLine and EndLine are the lines holding the start and end markers, or the front matter delimiters. They are 0
+	// if the header was not found within the file.
+	StartLine int
+	EndLine   int
+
+	//Sidecar is the path of the sidecar file whose fields were merged into the header, if any
+	Sidecar string
+
+	Fields      []Field
+	Diagnostics []Diagnostic
+
+	//syntax is the comment syntax the header was found with
+	syntax commentSyntax
+
+	//meta holds the first and last lines of each run of front matter lines holding GOGIST fields
+	meta [][2]int
+
+	//allowUnknown suppresses the warning for unknown keys, used for front matter shared with other tools
+	allowUnknown bool
+}
+
+const (
+	//SourceComment headers are written in a comment between 'start gist' and 'end gist' labels
+	SourceComment = "comment"
+
+	//SourceYAML headers are taken from YAML front matter delimited by --- lines
+	SourceYAML = "yaml"
+
+	//SourceTOML headers are taken from TOML front matter delimited by +++ lines
+	SourceTOML = "toml"
+
+	//SourceSidecar headers are taken entirely from a sidecar file
+	SourceSidecar = "sidecar"
+)
+
+//Found reports whether the file has a complete header, either within the file or from a sidecar
+func (h *Header) Found() bool {
+	return h.inFile() || h.Sidecar != ""
+}
+
+//inFile reports whether both the start and end of a header were found within the file
+func (h *Header) inFile() bool {
+	return h.StartLine > 0 && h.EndLine > 0
+}
+
+//Get returns the field with the given key
+func (h *Header) Get(key string) (Field, bool) {
+	key = strings.ToLower(key)
+	for _, f := range h.Fields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+//Err returns the first error diagnostic, or nil if the header is valid
+func (h *Header) Err() error {
+	for _, d := range h.Diagnostics {
+		if d.Severity == SeverityError {
+			return d
+		}
+	}
+	return nil
+}
+
+//Warnings returns the warning diagnostics
+func (h *Header) Warnings() []Diagnostic {
+	warnings := make([]Diagnostic, 0)
+	for _, d := range h.Diagnostics {
+		if d.Severity == SeverityWarning {
+			warnings = append(warnings, d)
+		}
+	}
+	return warnings
+}
+
+func (h *Header) addDiagnostic(severity Severity, line, column int, format string, args ...interface{}) {
+	h.Diagnostics = append(h.Diagnostics, Diagnostic{
+		File:     h.File,
+		Line:     line,
+		Column:   column,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+var (
+	markerRegexp = regexp.MustCompile(`^(start|end) (go)?gist$`)
+	fieldRegexp  = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_-]*)[ \t]*:[ \t]*(.*)$`)
+)
+
+//ParseHeader parses the GOGIST header of a file. The file name selects the comment syntax the header is expected to be
+// written in and annotates diagnostics. Markers and fields are only recognised inside comments, so code that happens
+// to contain the words 'start gist' is never mistaken for a header. Files of unknown type, or whose header is not
+// written in the comment syntax of their language, are parsed with a generic syntax understanding the most common
+// comment styles.
+func ParseHeader(file string, content []byte) *Header {
+	lines := splitLines(string(content))
+	language, syntax := syntaxFor(file)
+
+	if h := parseFrontMatter(file, lines); h != nil {
+		h.Language = language
+		return h
+	}
+
+	h := parseHeader(file, lines, syntax)
+	h.Language = language
+	if h.Found() || language == "" {
+		return h
+	}
+
+	if generic := parseHeader(file, lines, genericSyntax); generic.Found() {
+		generic.Language = language
+		generic.addDiagnostic(SeverityWarning, generic.StartLine, 1,
+			"the GOGIST header does not use %s comment syntax", language)
+		return generic
+	}
+	return h
+}
+
+//headerLine is a line between the start and end labels of a header
+type headerLine struct {
+	line int
+
+	//text is the comment text of a comment line, or the whole line for code
+	text string
+
+	//column is the column of the first non blank character of text
+	column int
+
+	comment bool
+	blank   bool
+}
+
+//parseHeader parses the GOGIST header from lines using the given comment syntax.
+func parseHeader(file string, lines []string, syntax commentSyntax) *Header {
+	h := &Header{File: file, Source: SourceComment, syntax: syntax}
+	comments := scanComments(lines, syntax)
+
+	var body []headerLine
+	for i, c := range comments {
+		lineNo := i + 1
+		text := strings.TrimSpace(c.text)
+		column := c.column + strings.Index(c.text, text)
+
+		var marker []string
+		if c.isComment {
+			marker = markerRegexp.FindStringSubmatch(strings.ToLower(strings.Join(strings.Fields(text), " ")))
+		}
+		if h.StartLine == 0 {
+			if marker != nil && marker[1] == "start" {
+				h.StartLine = lineNo
+			} else if marker != nil {
+				h.addDiagnostic(SeverityWarning, lineNo, column, "'end gist' label before any 'start gist' label")
+			}
+			continue
+		}
+
+		if marker != nil && marker[1] == "end" {
+			h.EndLine = lineNo
+			break
+		}
+		if marker != nil {
+			h.addDiagnostic(SeverityError, lineNo, column, "second 'start gist' label, the header began on line %d",
+				h.StartLine)
+			continue
+		}
+
+		body = append(body, headerLine{
+			line:    lineNo,
+			text:    c.text,
+			column:  column,
+			comment: c.isComment,
+			blank:   text == "",
+		})
+	}
+	h.parseFields(body)
+
+	switch {
+	case h.StartLine == 0:
+		h.addDiagnostic(SeverityError, 0, 0, "is not a suitable GOGIST file. Add the following string 'start GOGIST' "+
+			"inside a comment section at the top of the file to mark it as a file gist can gist ;-)")
+	case h.EndLine == 0:
+		h.addDiagnostic(SeverityError, h.StartLine, 1, "is not a suitable GOGIST file. Add the following string "+
+			"'end GOGIST' inside a comment section at the top of the file to mark it as a file gist can gist ;-). "+
+			"This should be after the start Gogist section")
+	}
+	return h
+}
+
+//parseFields parses the 'key: value' fields from the lines between the start and end labels. A value continues on
+// the following lines for as long as they are indented further than its key, see parseValue. An indented line that
+// sets a known key starts a new field instead, unless the value is a block scalar, so headers whose keys are not
+// aligned keep every field.
+func (h *Header) parseFields(body []headerLine) {
+	codeWarned := false
+	for i := 0; i < len(body); {
+		l := body[i]
+		if l.blank {
+			i++
+			continue
+		}
+		if !l.comment {
+			if !codeWarned {
+				h.addDiagnostic(SeverityWarning, l.line, l.column, "code inside the GOGIST header is ignored")
+				codeWarned = true
+			}
+			i++
+			continue
+		}
+
+		text := strings.TrimSpace(l.text)
+		match := fieldRegexp.FindStringSubmatch(text)
+		if match == nil {
+			h.addDiagnostic(SeverityWarning, l.line, l.column, "expected 'key: value', line is ignored")
+			i++
+			continue
+		}
+
+		first := strings.TrimSpace(match[2])
+		block := strings.HasPrefix(first, "|") || strings.HasPrefix(first, ">")
+		j := i + 1
+		for j < len(body) && (body[j].blank || body[j].column > l.column) {
+			if !block && startsField(body[j]) {
+				break
+			}
+			j++
+		}
+		for j > i+1 && body[j-1].blank {
+			j--
+		}
+
+		field := Field{
+			Key:     strings.ToLower(match[1]),
+			Line:    l.line,
+			EndLine: body[j-1].line,
+			Column:  l.column,
+		}
+		value, err := parseValue(first, body[i+1:j])
+		if err != "" {
+			h.addDiagnostic(SeverityError, l.line, l.column+strings.Index(text, match[2]), "%s", err)
+		}
+		field.Value = value
+		h.addField(match[1], field)
+		i = j
+	}
+}
+
+//startsField reports whether l is a comment line setting a known key
+func startsField(l headerLine) bool {
+	if !l.comment {
+		return false
+	}
+	match := fieldRegexp.FindStringSubmatch(strings.TrimSpace(l.text))
+	return match != nil && isKnownKey(strings.ToLower(match[1]))
+}
+
+//addField records a parsed field, reporting duplicate and unknown keys. key is the key as written.
+func (h *Header) addField(key string, field Field) {
+	if previous, ok := h.Get(field.Key); ok {
+		h.addDiagnostic(SeverityError, field.Line, field.Column, "duplicate key %q, it is already set on line %d", key,
+			previous.Line)
+		return
+	}
+	if !isKnownKey(field.Key) && !h.allowUnknown {
+		h.addDiagnostic(SeverityWarning, field.Line, field.Column, "unknown key %q", key)
+	}
+	h.Fields = append(h.Fields, field)
+}
+
+func isKnownKey(key string) bool {
+	for _, k := range KnownKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+//splitLines splits content into lines, dropping the line terminators
+func splitLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	return lines
+}
@@ -0,0 +1,76 @@
+package gists
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantFields   map[string]string
+		wantStart    int
+		wantEnd      int
+		wantErr      bool
+		wantWarnings []string
+	}{
+		{name: "block comment", content: "/* Start GOGIST\n   Author: Me\n   Description: Hi\n   end gist\n*/\n",
+			wantFields: map[string]string{"author": "Me", "description": "Hi"}, wantStart: 1, wantEnd: 4},
+		{name: "line comments", content: "package x\n// start gist\n// public: false\n// end gist\n",
+			wantFields: map[string]string{"public": "false"}, wantStart: 2, wantEnd: 4},
+		{name: "keys are matched exactly", content: "# start gist\n# description: is public by author\n# end gist\n",
+			wantFields: map[string]string{"description": "is public by author"}, wantStart: 1, wantEnd: 3},
+		{name: "marker in code is ignored", content: "s := \"start gist\"\n// start gist\n// end gist\n",
+			wantFields: map[string]string{}, wantStart: 2, wantEnd: 3},
+		{name: "duplicate key", content: "// start gist\n// author: a\n//  Author: b\n// end gist\n",
+			wantFields: map[string]string{"author": "a"}, wantStart: 1, wantEnd: 4, wantErr: true},
+		{name: "unaligned keys", content: "/* start gist\n\tAuthor: Me\n  Description: Hi\n  Public: false\n" +
+			"  end gist\n*/\n", wantFields: map[string]string{"author": "Me", "description": "Hi", "public": "false"},
+			wantStart: 1, wantEnd: 5},
+		{name: "indented key", content: "// start gist\n// author: a\n//   public: false\n// end gist\n",
+			wantFields: map[string]string{"author": "a", "public": "false"}, wantStart: 1, wantEnd: 4},
+		{name: "indented continuation", content: "// start gist\n// description: a\n//   note: b\n// end gist\n",
+			wantFields: map[string]string{"description": "a note: b"}, wantStart: 1, wantEnd: 4},
+		{name: "block scalar", content: "// start gist\n// description: |\n//   public: false\n// end gist\n",
+			wantFields: map[string]string{"description": "public: false\n"}, wantStart: 1, wantEnd: 4},
+		{name: "unknown key", content: "// start gist\n// colour: blue\n// end gist\n",
+			wantFields: map[string]string{"colour": "blue"}, wantStart: 1, wantEnd: 3,
+			wantWarnings: []string{"f:2:4: warning: unknown key \"colour\""}},
+		{name: "not a field", content: "// start gist\n// author martin\n// end gist\n",
+			wantFields: map[string]string{}, wantStart: 1, wantEnd: 3,
+			wantWarnings: []string{"f:2:4: warning: expected 'key: value', line is ignored"}},
+		{name: "missing end", content: "// start gist\n// author: a\n", wantFields: map[string]string{"author": "a"},
+			wantStart: 1, wantErr: true},
+		{name: "missing start", content: "// author: a\n// end gist\n", wantFields: map[string]string{},
+			wantErr: true, wantWarnings: []string{"f:2:4: warning: 'end gist' label before any 'start gist' label"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := ParseHeader("f", []byte(tt.content))
+			if (h.Err() != nil) != tt.wantErr {
+				t.Errorf("ParseHeader() error = %v, wantErr %v", h.Err(), tt.wantErr)
+			}
+			if h.StartLine != tt.wantStart || h.EndLine != tt.wantEnd {
+				t.Errorf("ParseHeader() lines = %d-%d, want %d-%d", h.StartLine, h.EndLine, tt.wantStart, tt.wantEnd)
+			}
+			fields := map[string]string{}
+			for _, f := range h.Fields {
+				fields[f.Key] = f.Value
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("ParseHeader() fields = %v, want %v", fields, tt.wantFields)
+			}
+			warnings := []string{}
+			for _, w := range h.Warnings() {
+				warnings = append(warnings, w.Error())
+			}
+			if tt.wantWarnings == nil {
+				tt.wantWarnings = []string{}
+			}
+			if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+				t.Errorf("ParseHeader() warnings = %v, want %v", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package gists
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+//language associates file extensions, and a few well known file names, with the comment syntax a GOGIST header
+// may be written in
+type language struct {
+	name       string
+	extensions []string
+	filenames  []string
+	syntax     commentSyntax
+}
+
+var (
+	cSyntax       = commentSyntax{line: []string{"//"}, block: [][2]string{{"/*", "*/"}}}
+	hashSyntax    = commentSyntax{line: []string{"#"}}
+	dashSyntax    = commentSyntax{line: []string{"--"}}
+	xmlSyntax     = commentSyntax{block: [][2]string{{"<!--", "-->"}}}
+	semiSyntax    = commentSyntax{line: []string{";"}}
+	percentSyntax = commentSyntax{line: []string{"%"}}
+)
+
+//languages is the table consulted by syntaxFor. Files whose language is not listed are parsed with genericSyntax.
+var languages = []language{
+	{name: "C", extensions: []string{".c", ".h"}, syntax: cSyntax},
+	{name: "C++", extensions: []string{".cc", ".cpp", ".cxx", ".hpp", ".hh", ".hxx"}, syntax: cSyntax},
+	{name: "Objective-C", extensions: []string{".m", ".mm"}, syntax: cSyntax},
+	{name: "Go", extensions: []string{".go"}, syntax: cSyntax},
+	{name: "Java", extensions: []string{".java"}, syntax: cSyntax},
+	{name: "Kotlin", extensions: []string{".kt", ".kts"}, syntax: cSyntax},
+	{name: "Scala", extensions: []string{".scala"}, syntax: cSyntax},
+	{name: "Groovy", extensions: []string{".groovy", ".gradle"}, syntax: cSyntax},
+	{name: "JavaScript", extensions: []string{".js", ".mjs", ".cjs", ".jsx"}, syntax: cSyntax},
+	{name: "TypeScript", extensions: []string{".ts", ".tsx"}, syntax: cSyntax},
+	{name: "C#", extensions: []string{".cs"}, syntax: cSyntax},
+	{name: "F#", extensions: []string{".fs", ".fsi", ".fsx"}, syntax: commentSyntax{line: []string{"//"},
+		block: [][2]string{{"(*", "*)"}}}},
+	{name: "Rust", extensions: []string{".rs"}, syntax: cSyntax},
+	{name: "Swift", extensions: []string{".swift"}, syntax: cSyntax},
+	{name: "Dart", extensions: []string{".dart"}, syntax: cSyntax},
+	{name: "Zig", extensions: []string{".zig"}, syntax: commentSyntax{line: []string{"//"}}},
+	{name: "CSS", extensions: []string{".css"}, syntax: commentSyntax{block: cSyntax.block}},
+	{name: "SCSS", extensions: []string{".scss"}, syntax: cSyntax},
+	{name: "Less", extensions: []string{".less"}, syntax: cSyntax},
+	{name: "PHP", extensions: []string{".php"}, syntax: commentSyntax{line: []string{"//", "#"}, block: cSyntax.block}},
+	{name: "Python", extensions: []string{".py", ".pyw", ".pyi"},
+		syntax: commentSyntax{line: []string{"#"}, block: [][2]string{{`"""`, `"""`}, {"'''", "'''"}}}},
+	{name: "Shell", extensions: []string{".sh", ".bash", ".zsh", ".fish", ".ksh"}, syntax: hashSyntax},
+	{name: "Ruby", extensions: []string{".rb"}, syntax: commentSyntax{line: []string{"#"},
+		block: [][2]string{{"=begin", "=end"}}}},
+	{name: "Perl", extensions: []string{".pl", ".pm"}, syntax: hashSyntax},
+	{name: "R", extensions: []string{".r"}, syntax: hashSyntax},
+	{name: "PowerShell", extensions: []string{".ps1", ".psm1"}, syntax: commentSyntax{line: []string{"#"},
+		block: [][2]string{{"<#", "#>"}}}},
+	{name: "YAML", extensions: []string{".yaml", ".yml"}, syntax: hashSyntax},
+	{name: "TOML", extensions: []string{".toml"}, syntax: hashSyntax},
+	{name: "INI", extensions: []string{".ini", ".cfg"}, syntax: commentSyntax{line: []string{";", "#"}}},
+	{name: "Dotenv", extensions: []string{".env"}, syntax: hashSyntax},
+	{name: "Config", extensions: []string{".conf"}, syntax: hashSyntax},
+	{name: "Make", extensions: []string{".mk"}, filenames: []string{"makefile", "gnumakefile"}, syntax: hashSyntax},
+	{name: "CMake", extensions: []string{".cmake"}, filenames: []string{"cmakelists.txt"}, syntax: hashSyntax},
+	{name: "Dockerfile", filenames: []string{"dockerfile"}, syntax: hashSyntax},
+	{name: "SQL", extensions: []string{".sql"}, syntax: commentSyntax{line: []string{"--"}, block: cSyntax.block}},
+	{name: "Lua", extensions: []string{".lua"}, syntax: commentSyntax{line: []string{"--"},
+		block: [][2]string{{"--[[", "]]"}}}},
+	{name: "Haskell", extensions: []string{".hs"}, syntax: commentSyntax{line: []string{"--"},
+		block: [][2]string{{"{-", "-}"}}}},
+	{name: "Elm", extensions: []string{".elm"}, syntax: commentSyntax{line: []string{"--"},
+		block: [][2]string{{"{-", "-}"}}}},
+	{name: "Ada", extensions: []string{".ada", ".adb", ".ads"}, syntax: dashSyntax},
+	{name: "VHDL", extensions: []string{".vhd", ".vhdl"}, syntax: dashSyntax},
+	{name: "HTML", extensions: []string{".html", ".htm", ".xhtml"}, syntax: xmlSyntax},
+	{name: "XML", extensions: []string{".xml"}, syntax: xmlSyntax},
+	{name: "SVG", extensions: []string{".svg"}, syntax: xmlSyntax},
+	{name: "Vue", extensions: []string{".vue"}, syntax: xmlSyntax},
+	{name: "Common Lisp", extensions: []string{".lisp"}, syntax: semiSyntax},
+	{name: "Emacs Lisp", extensions: []string{".el"}, syntax: semiSyntax},
+	{name: "Clojure", extensions: []string{".clj", ".cljs"}, syntax: semiSyntax},
+	{name: "Scheme", extensions: []string{".scm"}, syntax: semiSyntax},
+	{name: "Assembly", extensions: []string{".asm"}, syntax: semiSyntax},
+	{name: "Erlang", extensions: []string{".erl", ".hrl"}, syntax: percentSyntax},
+	{name: "TeX", extensions: []string{".tex", ".sty"}, syntax: percentSyntax},
+	{name: "OCaml", extensions: []string{".ml", ".mli"}, syntax: commentSyntax{block: [][2]string{{"(*", "*)"}}}},
+	{name: "Vim", extensions: []string{".vim"}, syntax: commentSyntax{line: []string{`"`}}},
+	{name: "Batch", extensions: []string{".bat", ".cmd"}, syntax: commentSyntax{line: []string{"::", "REM ", "rem "}}},
+}
+
+//lookupLanguage returns the language of the file at path, or nil if it is not in the languages table.
+func lookupLanguage(path string) *language {
+	base := strings.ToLower(filepath.Base(path))
+	ext := filepath.Ext(base)
+	for i := range languages {
+		for _, name := range languages[i].filenames {
+			if name == base {
+				return &languages[i]
+			}
+		}
+		for _, e := range languages[i].extensions {
+			if e == ext {
+				return &languages[i]
+			}
+		}
+	}
+	return nil
+}
+
+//syntaxFor returns the name of the language of the file at path and its comment syntax, falling back to
+// genericSyntax for unknown file types.
+func syntaxFor(path string) (string, commentSyntax) {
+	if lang := lookupLanguage(path); lang != nil {
+		return lang.name, lang.syntax
+	}
+	return "", genericSyntax
+}
@@ -0,0 +1,98 @@
+package gists
+
+import (
+	"testing"
+)
+
+func TestParseHeader_languages(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		content      string
+		wantLanguage string
+		wantAuthor   string
+		wantFound    bool
+	}{
+		{"go line comments", "main.go", "// start gist\n// author: gopher\n// end gist\npackage main\n", "Go",
+			"gopher", true},
+		{"c include is code", "main.c", "#include <stdio.h>\n/* start gist\n * author: dmr\n * end gist */\n", "C",
+			"dmr", true},
+		{"python hash", "app.py", "#!/usr/bin/env python\n# start gist\n# author: guido\n# end gist\n", "Python",
+			"guido", true},
+		{"python docstring", "app.py", "\"\"\"\nstart gist\nauthor: guido\nend gist\n\"\"\"\nimport os\n", "Python",
+			"guido", true},
+		{"shell", "run.sh", "#!/bin/sh\n# Start GOGIST\n#   Author: bourne\n# End GOGIST\necho hi\n", "Shell",
+			"bourne", true},
+		{"sql", "q.sql", "-- start gist\n-- author: codd\n-- end gist\nSELECT 1;\n", "SQL", "codd", true},
+		{"yaml", "ci.yml", "# start gist\n# author: ops\n# end gist\nkey: value\n", "YAML", "ops", true},
+		{"html", "index.html", "<!-- start gist\n  author: tim\n  end gist -->\n<html></html>\n", "HTML", "tim",
+			true},
+		{"python single quoted docstring", "app.py", "'''\nstart gist\nauthor: guido\nend gist\n'''\nx = f(a, b)\n",
+			"Python", "guido", true},
+		{"lua line", "init.lua", "-- start gist\n-- author: roberto\n-- end gist\n", "Lua", "roberto", true},
+		{"lua block", "init.lua", "--[[ start gist\nauthor: roberto\nend gist ]]\nprint(1)\n", "Lua", "roberto",
+			true},
+		{"sql marker in code is ignored", "q.sql", "SELECT '-- start gist';\n", "SQL", "", false},
+		{"foreign syntax falls back", "app.py", "// start gist\n// author: someone\n// end gist\n", "Python",
+			"someone", true},
+		{"unknown extension", "notes.weird", "% start gist\n% author: anon\n% end gist\n", "", "anon", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := ParseHeader(tt.file, []byte(tt.content))
+			if h.Language != tt.wantLanguage {
+				t.Errorf("ParseHeader() language = %q, want %q", h.Language, tt.wantLanguage)
+			}
+			if h.Found() != tt.wantFound {
+				t.Fatalf("ParseHeader() found = %v, want %v: %v", h.Found(), tt.wantFound, h.Diagnostics)
+			}
+			author, _ := h.Get("author")
+			if author.Value != tt.wantAuthor {
+				t.Errorf("ParseHeader() author = %q, want %q", author.Value, tt.wantAuthor)
+			}
+		})
+	}
+}
+
+func TestLookupLanguage(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"main.cpp", "C++"},
+		{"view.m", "Objective-C"},
+		{"build.gradle", "Groovy"},
+		{"app.ts", "TypeScript"},
+		{"lib.fs", "F#"},
+		{"main.dart", "Dart"},
+		{"main.zig", "Zig"},
+		{"theme.less", "Less"},
+		{"setup.cfg", "INI"},
+		{"php.ini", "INI"},
+		{".env", "Dotenv"},
+		{"nginx.conf", "Config"},
+		{"Dockerfile", "Dockerfile"},
+		{"CMakeLists.txt", "CMake"},
+		{"Makefile", "Make"},
+		{"Main.elm", "Elm"},
+		{"top.vhdl", "VHDL"},
+		{"pom.xml", "XML"},
+		{"init.el", "Emacs Lisp"},
+		{"core.clj", "Clojure"},
+		{"boot.asm", "Assembly"},
+		{"paper.tex", "TeX"},
+		{"start.s", ""},
+		{"rules.pro", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got := ""
+			if lang := lookupLanguage(tt.file); lang != nil {
+				got = lang.name
+			}
+			if got != tt.want {
+				t.Errorf("lookupLanguage(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package gists
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//Region is a named part of a file, marked by comment lines reading 'gist:region <name>' and 'gist:endregion'. When a
+// file has regions only they are published, each as a file of the same gist, rather than the whole file.
+//
+//	// gist:region handler
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		fmt.Fprintln(w, "hello")
+//	}
+//	// gist:endregion
+type Region struct {
+	Name string
+
+	//StartLine and EndLine are the lines holding the region and endregion markers
+	StartLine int
+	EndLine   int
+
+	//Content holds the lines between the markers, excluding the markers of any region nested within it
+	Content string
+}
+
+var (
+	regionRegexp     = regexp.MustCompile(`^gist:region(?:[ \t]+(.*))?$`)
+	endRegionRegexp  = regexp.MustCompile(`^gist:endregion(?:[ \t]+(.*))?$`)
+	regionNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+//ParseRegions returns the regions of a file in the order they start. Regions may nest, the name after
+// 'gist:endregion' is optional but must match the region it closes. Problems such as unclosed regions or duplicate
+// names are reported as error diagnostics.
+func ParseRegions(file string, content []byte) ([]Region, []Diagnostic) {
+	h := &Header{File: file}
+	raw := strings.SplitAfter(string(content), "\n")
+	lines := splitLines(string(content))
+	_, syntax := syntaxFor(file)
+	comments := scanComments(lines, syntax)
+
+	var regions []Region
+	var open []int
+	marker := make([]bool, len(lines))
+	for i, c := range comments {
+		if !c.isComment {
+			continue
+		}
+		text := strings.TrimSpace(c.text)
+		column := c.column + strings.Index(c.text, text)
+
+		if match := regionRegexp.FindStringSubmatch(text); match != nil {
+			marker[i] = true
+			name := strings.TrimSpace(match[1])
+			if !regionNameRegexp.MatchString(name) {
+				h.addDiagnostic(SeverityError, i+1, column, "region name %q must be letters, digits, '_', '-' or '.'",
+					name)
+				continue
+			}
+			if strings.Trim(name, ".") == "" {
+				h.addDiagnostic(SeverityError, i+1, column, "region name %q is not allowed", name)
+				continue
+			}
+			for _, r := range regions {
+				if r.Name == name {
+					h.addDiagnostic(SeverityError, i+1, column, "duplicate region %q, it already starts on line %d",
+						name, r.StartLine)
+				}
+			}
+			regions = append(regions, Region{Name: name, StartLine: i + 1})
+			open = append(open, len(regions)-1)
+			continue
+		}
+
+		if match := endRegionRegexp.FindStringSubmatch(text); match != nil {
+			marker[i] = true
+			if len(open) == 0 {
+				h.addDiagnostic(SeverityError, i+1, column, "'gist:endregion' without a 'gist:region'")
+				continue
+			}
+			r := &regions[open[len(open)-1]]
+			open = open[:len(open)-1]
+			if name := strings.TrimSpace(match[1]); name != "" && name != r.Name {
+				h.addDiagnostic(SeverityError, i+1, column, "'gist:endregion %s' closes region %q started on line %d",
+					name, r.Name, r.StartLine)
+			}
+			r.EndLine = i + 1
+		}
+	}
+	for _, i := range open {
+		h.addDiagnostic(SeverityError, regions[i].StartLine, 1, "region %q is never closed", regions[i].Name)
+	}
+	if h.Err() != nil {
+		return nil, h.Diagnostics
+	}
+
+	for i := range regions {
+		var b strings.Builder
+		for j := regions[i].StartLine; j < regions[i].EndLine-1; j++ {
+			if !marker[j] {
+				b.WriteString(raw[j])
+			}
+		}
+		regions[i].Content = b.String()
+	}
+	return regions, nil
+}
+
+//Dedent removes the indentation shared by every non blank line of content
+func Dedent(content string) string {
+	prefix := sharedIndent(content)
+	if prefix == "" {
+		return content
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+		if strings.TrimSpace(lines[i]) == "" {
+			lines[i] = strings.TrimLeft(lines[i], " \t")
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+//sharedIndent returns the indentation shared by every non blank line of content, the prefix Dedent removes
+func sharedIndent(content string) string {
+	prefix := ""
+	first := true
+	for _, line := range strings.SplitAfter(content, "\n") {
+		text := strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+//regionFilename returns the name region is published under given the file name of the whole file. A region name
+// with an extension is used as is, otherwise it is added to the file name before its extension, so region 'handler'
+// of main.go becomes main-handler.go. Names made of dots only, which ParseRegions rejects, are never used as is.
+func regionFilename(filename, region string) string {
+	if filepath.Ext(region) != "" && strings.Trim(region, ".") != "" {
+		return region
+	}
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-" + region + ext
+}
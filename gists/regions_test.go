@@ -0,0 +1,91 @@
+package gists
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRegions(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []Region
+		wantErr bool
+	}{
+		{"none", "main.go", "package main\n", nil, false},
+		{"single", "main.go", "package main\n// gist:region handler\nfunc h() {}\n// gist:endregion\n",
+			[]Region{{Name: "handler", StartLine: 2, EndLine: 4, Content: "func h() {}\n"}}, false},
+		{"nested markers are dropped", "run.py",
+			"# gist:region outer\na = 1\n# gist:region inner\nb = 2\n# gist:endregion inner\n# gist:endregion\n",
+			[]Region{
+				{Name: "outer", StartLine: 1, EndLine: 6, Content: "a = 1\nb = 2\n"},
+				{Name: "inner", StartLine: 3, EndLine: 5, Content: "b = 2\n"},
+			}, false},
+		{"markers in code are ignored", "main.go", "s := \"gist:region x\"\n", nil, false},
+		{"unclosed", "main.go", "// gist:region a\n", nil, true},
+		{"stray end", "main.go", "// gist:endregion\n", nil, true},
+		{"mismatched end", "main.go", "// gist:region a\n// gist:endregion b\n", nil, true},
+		{"duplicate", "main.go", "// gist:region a\n// gist:endregion\n// gist:region a\n// gist:endregion\n", nil,
+			true},
+		{"missing name", "main.go", "// gist:region\n// gist:endregion\n", nil, true},
+		{"dot name", "main.go", "// gist:region .\n// gist:endregion\n", nil, true},
+		{"dot dot name", "main.go", "// gist:region ..\n// gist:endregion\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diagnostics := ParseRegions(tt.file, []byte(tt.content))
+			if (len(diagnostics) > 0) != tt.wantErr {
+				t.Fatalf("ParseRegions() diagnostics = %v, wantErr %v", diagnostics, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRegions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"tabs", "\tfunc f() {\n\t\treturn\n\t}\n", "func f() {\n\treturn\n}\n"},
+		{"blank lines", "    a\n\n  \n      b\n", "a\n\n\n  b\n"},
+		{"nothing shared", "a\n  b\n", "a\n  b\n"},
+		{"mixed indentation", "\t a\n\t  b\n", "a\n b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Dedent(tt.content); got != tt.want {
+				t.Errorf("Dedent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegionFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		region   string
+		want     string
+	}{
+		{"main.go", "handler", "main-handler.go"},
+		{"Makefile", "build", "Makefile-build"},
+		{"main.go", "server.go", "server.go"},
+		{"main.go", ".", "main-..go"},
+		{"main.go", "..", "main-...go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			got := regionFilename(tt.filename, tt.region)
+			if got != tt.want {
+				t.Errorf("regionFilename() = %v, want %v", got, tt.want)
+			}
+			if err := ValidateFilename(got); err != nil {
+				t.Errorf("regionFilename() = %v -> %s", got, err)
+			}
+		})
+	}
+}
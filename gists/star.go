@@ -0,0 +1,76 @@
+package gists
+
+import (
+	"context"
+	"fmt"
+	"github.com/martinomburajr/gist/auth"
+	"net/http"
+)
+
+//Star stars the gist with the given id for the authenticated user, or unstars it if starred is false
+//https://developer.github.com/v3/gists/#star-a-gist
+func Star(id string, starred bool) error {
+	return StarContext(context.Background(), id, starred)
+}
+
+//StarContext is Star with a context, see auth.WithToken
+func StarContext(ctx context.Context, id string, starred bool) error {
+	method := http.MethodPut
+	if !starred {
+		method = http.MethodDelete
+	}
+	resp, err := doStar(ctx, method, id)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("could not star gist %s -> %s", id, resp.Status)
+	}
+	return nil
+}
+
+//Starred reports whether the authenticated user starred the gist with the given id
+//https://developer.github.com/v3/gists/#check-if-a-gist-is-starred
+func Starred(id string) (bool, error) {
+	return StarredContext(context.Background(), id)
+}
+
+//StarredContext is Starred with a context, see auth.WithToken
+func StarredContext(ctx context.Context, id string) (bool, error) {
+	resp, err := doStar(ctx, http.MethodGet, id)
+	if err != nil {
+		return false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("could not check the star of gist %s -> %s", id, resp.Status)
+}
+
+//ListStarred returns every gist the authenticated user starred, theirs or not, like List
+//https://developer.github.com/v3/gists/#list-starred-gists
+func ListStarred() ([]*GistFile, error) {
+	return ListStarredContext(context.Background())
+}
+
+//ListStarredContext is ListStarred with a context, see auth.WithToken
+func ListStarredContext(ctx context.Context) ([]*GistFile, error) {
+	return list(ctx, EndpointBase+EndpointGistCreate+"/starred?per_page=100")
+}
+
+//doStar sends a request with the given method to the star of the gist id, the body of the response is closed
+func doStar(ctx context.Context, method, id string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, GistURL(id)+"/star", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := auth.Session.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
@@ -0,0 +1,64 @@
+package gists
+
+import (
+	"github.com/martinomburajr/gist/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStar(t *testing.T) {
+	starred := map[string]bool{"abc": true}
+	client := auth.Session.Client
+	defer func() { auth.Session.Client = client }()
+	auth.Session.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		id := r.URL.Path[len("/gists/") : len(r.URL.Path)-len("/star")]
+		switch {
+		case id == "missing":
+			recorder.WriteHeader(http.StatusNotFound)
+			if r.Method != http.MethodGet {
+				return recorder.Result(), nil
+			}
+			recorder = httptest.NewRecorder()
+			recorder.WriteHeader(http.StatusInternalServerError)
+		case r.Method == http.MethodPut:
+			starred[id] = true
+			recorder.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			delete(starred, id)
+			recorder.WriteHeader(http.StatusNoContent)
+		case starred[id]:
+			recorder.WriteHeader(http.StatusNoContent)
+		default:
+			recorder.WriteHeader(http.StatusNotFound)
+		}
+		return recorder.Result(), nil
+	})}
+
+	tests := []struct {
+		name    string
+		id      string
+		star    bool
+		want    bool
+		wantErr bool
+	}{
+		{"star", "def", true, true, false},
+		{"unstar", "abc", false, false, false},
+		{"missing gist", "missing", true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Star(tt.id, tt.star); (err != nil) != tt.wantErr {
+				t.Fatalf("Star() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := Starred(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Starred() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Starred() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
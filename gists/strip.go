@@ -0,0 +1,118 @@
+package gists
+
+import (
+	"strings"
+)
+
+//Strip returns content with the GOGIST header removed, content must be what the header was parsed from. If the
+// comment holding the header contains nothing else the whole comment is removed, otherwise only the lines from the
+// start label to the end label are, leaving the rest of the comment and its delimiters intact. Front matter is
+// treated the same way, see stripFrontMatter. content is returned unchanged if no header was found within it.
+func (h *Header) Strip(content []byte) []byte {
+	if !h.inFile() {
+		return content
+	}
+	if h.Source == SourceYAML || h.Source == SourceTOML {
+		return h.stripFrontMatter(content)
+	}
+
+	raw := strings.SplitAfter(string(content), "\n")
+	lines := splitLines(string(content))
+	comments := scanComments(lines, h.syntax)
+	start, end := h.StartLine-1, h.EndLine-1
+
+	first, last := start, end
+	if comments[start].block {
+		for first > 0 && !comments[first].opens {
+			first--
+		}
+		for last < len(comments)-1 && !comments[last].closes {
+			last++
+		}
+	} else {
+		for first > 0 && comments[first-1].isComment && !comments[first-1].block {
+			first--
+		}
+		for last < len(comments)-1 && comments[last+1].isComment && !comments[last+1].block {
+			last++
+		}
+	}
+
+	partial := false
+	for i := first; i <= last; i++ {
+		if (i < start || i > end) && strings.TrimSpace(comments[i].text) != "" {
+			partial = true
+			break
+		}
+	}
+
+	if partial {
+		first, last = start, end
+	}
+
+	//code between the labels is never removed, only the comment lines of the header and the lines its values
+	// continue onto
+	drop := make([]bool, len(raw))
+	for i := first; i <= last; i++ {
+		drop[i] = comments[i].isComment || strings.TrimSpace(lines[i]) == ""
+	}
+	for _, f := range h.Fields {
+		for i := f.Line; i <= f.EndLine; i++ {
+			drop[i-1] = true
+		}
+	}
+
+	//the start label may share its line with the comment opener, or the end label with the closer and possibly some
+	// code, in which case that part of the line is kept
+	replace := map[int]string{}
+	if c := comments[start]; partial && c.opens {
+		replace[start] = strings.TrimRight(lines[start][:c.column-1], " \t") + lineEnding(raw[start])
+	} else if c := comments[last]; c.closes {
+		after := lines[last][c.column-1+len(c.text):]
+		if !partial {
+			for _, pair := range h.syntax.block {
+				if strings.HasPrefix(after, pair[1]) {
+					after = after[len(pair[1]):]
+					break
+				}
+			}
+		}
+		if strings.TrimSpace(after) != "" {
+			indent := ""
+			if partial {
+				indent = lines[last][:len(lines[last])-len(strings.TrimLeft(lines[last], " \t"))]
+			}
+			replace[last] = indent + strings.TrimSpace(after) + lineEnding(raw[last])
+		}
+	}
+
+	out := make([]string, 0, len(raw))
+	trimBlank := first == 0 && len(replace) == 0
+	for i := range raw {
+		if r, ok := replace[i]; ok {
+			out = append(out, r)
+			continue
+		}
+		if drop[i] {
+			continue
+		}
+		if trimBlank && i > last && strings.TrimSpace(raw[i]) == "" {
+			trimBlank = false
+			continue
+		}
+		trimBlank = trimBlank && i < first
+		out = append(out, raw[i])
+	}
+	return []byte(strings.Join(out, ""))
+}
+
+//lineEnding returns the line terminator of a line split with strings.SplitAfter
+func lineEnding(line string) string {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return "\n"
+	}
+	return ""
+}
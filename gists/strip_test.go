@@ -0,0 +1,50 @@
+package gists
+
+import (
+	"testing"
+)
+
+func TestHeader_Strip(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"whole block comment", "main.go",
+			"/*\n\tStart gist\n\t\tdescription: d\n\tend gist\n */\npackage main\n",
+			"package main\n"},
+		{"block comment sharing the start label", "x.rand",
+			"this is a file\n/* START GOGIST\n    Author: Me\n    END GOGIST\n*/\n\nfunc f() {}\n",
+			"this is a file\n\nfunc f() {}\n"},
+		{"block comment with other text", "main.go",
+			"/* Package main does things.\n   start gist\n   author: me\n   end gist\n*/\npackage main\n",
+			"/* Package main does things.\n*/\npackage main\n"},
+		{"other text after the header", "main.go",
+			"/* start gist\n   author: me\n   end gist\n   Package main does things. */\npackage main\n",
+			"/*\n   Package main does things. */\npackage main\n"},
+		{"line comments", "run.sh",
+			"#!/bin/sh\n# start gist\n# author: me\n# end gist\necho hi\n",
+			"#!/bin/sh\necho hi\n"},
+		{"code between the labels is kept", "x.e",
+			"#start gist\n#author: me\nserver() -> ok.\n#end gist\n",
+			"server() -> ok.\n"},
+		{"crlf line endings", "main.go",
+			"// start gist\r\n// author: me\r\n// end gist\r\n\r\npackage main\r\n",
+			"package main\r\n"},
+		{"closer followed by code", "main.go",
+			"/* start gist\n   end gist */ package main\n",
+			"package main\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := ParseHeader(tt.file, []byte(tt.content))
+			if !h.Found() {
+				t.Fatalf("ParseHeader() found no header: %v", h.Diagnostics)
+			}
+			if got := string(h.Strip([]byte(tt.content))); got != tt.want {
+				t.Errorf("Header.Strip() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package gists
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//parseValue returns the value of a field given the text after its colon and the lines continuing it, i.e. those
+// indented further than its key. A non empty second return value describes why the value is malformed. Values
+// follow YAML conventions:
+//
+//	description: a plain value that is
+//	  folded onto a single line
+//	description: "double quoted, with \"escapes\"\tand: colons"
+//	description: 'single quoted, it''s simple'
+//	description: |
+//	  a literal block scalar,
+//	  its newlines are kept
+//	description: >-
+//	  a folded block scalar
+//	  without its final newline
+//
+//A blank line within a plain, quoted or folded value becomes a newline.
+func parseValue(first string, cont []headerLine) (string, string) {
+	if strings.HasPrefix(first, "|") || strings.HasPrefix(first, ">") {
+		return parseBlockScalar(first, cont)
+	}
+
+	parts := []string{first}
+	for _, l := range cont {
+		parts = append(parts, strings.TrimSpace(l.text))
+	}
+	value := foldLines(parts)
+
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+		return parseQuoted(value)
+	}
+	return value, ""
+}
+
+//foldLines joins lines with a space, turning each blank line into a newline
+func foldLines(lines []string) string {
+	var b strings.Builder
+	breaks := 0
+	for _, line := range lines {
+		if line == "" {
+			if b.Len() > 0 {
+				breaks++
+			}
+			continue
+		}
+		if b.Len() > 0 {
+			if breaks > 0 {
+				b.WriteString(strings.Repeat("\n", breaks))
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		breaks = 0
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+//parseQuoted unquotes a single or double quoted value. Double quoted values understand the same escapes as Go
+// string literals, within single quoted values '' stands for a single quote.
+func parseQuoted(value string) (string, string) {
+	quote := value[0]
+	end := -1
+	for i := 1; i < len(value); i++ {
+		if quote == '"' && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] != quote {
+			continue
+		}
+		if quote == '\'' && i+1 < len(value) && value[i+1] == '\'' {
+			i++
+			continue
+		}
+		end = i
+		break
+	}
+	if end < 0 {
+		return value, "unterminated quoted value"
+	}
+
+	if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return value, fmt.Sprintf("unexpected %q after quoted value", rest)
+	}
+
+	inner := value[1:end]
+	if quote == '\'' {
+		return strings.Replace(inner, "''", "'", -1), ""
+	}
+	unquoted, err := strconv.Unquote(`"` + strings.Replace(inner, "\n", `\n`, -1) + `"`)
+	if err != nil {
+		return value, "invalid escape sequence in quoted value"
+	}
+	return unquoted, ""
+}
+
+//parseBlockScalar parses a literal (|) or folded (>) block scalar. The indicator may be followed by - to drop the
+// final newline or + to keep every trailing newline, otherwise a single final newline is kept.
+func parseBlockScalar(indicator string, cont []headerLine) (string, string) {
+	chomp := strings.TrimSpace(indicator[1:])
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return "", fmt.Sprintf("unexpected %q after block scalar indicator %q", chomp, indicator[:1])
+	}
+
+	indent := -1
+	for _, l := range cont {
+		if !l.blank && (indent < 0 || l.column < indent) {
+			indent = l.column
+		}
+	}
+
+	lines := make([]string, 0, len(cont))
+	for _, l := range cont {
+		if l.blank {
+			lines = append(lines, "")
+			continue
+		}
+		lines = append(lines, strings.Repeat(" ", l.column-indent)+strings.TrimSpace(l.text))
+	}
+
+	var value string
+	if indicator[0] == '|' {
+		value = strings.Join(lines, "\n")
+	} else {
+		value = foldLines(lines)
+	}
+
+	trailing := len(value) - len(strings.TrimRight(value, "\n"))
+	value = strings.TrimRight(value, "\n")
+	switch {
+	case value == "":
+		return "", ""
+	case chomp == "-":
+		return value, ""
+	case chomp == "+":
+		return value + strings.Repeat("\n", trailing+1), ""
+	}
+	return value + "\n", ""
+}
+
+//formatValue returns value written so that parseValue reads it back unchanged, quoting it only when a plain value
+// would be misread.
+func formatValue(value string) string {
+	plain := value != "" && strings.TrimSpace(value) == value && !strings.ContainsAny(value, "\n\r\t") &&
+		!strings.ContainsAny(value[:1], `"'|>#`) && !strings.Contains(value, " #")
+	if plain {
+		return value
+	}
+	return strconv.Quote(value)
+}
@@ -0,0 +1,51 @@
+package gists
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHeader_values(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{"single line", "description: one line", "one line", false},
+		{"colons", "description: a: b: c", "a: b: c", false},
+		{"continuation", "description: first\n   second\n     third", "first second third", false},
+		{"value on next line", "description:\n   first\n   second", "first second", false},
+		{"blank line in continuation", "description: first\n   second\n\n   third", "first second\nthird", false},
+		{"double quoted", `description: "say \"hi\"\tnow: ok"`, "say \"hi\"\tnow: ok", false},
+		{"double quoted over lines", "description: \"first\n   second\"", "first second", false},
+		{"single quoted", "description: 'it''s # fine'", "it's # fine", false},
+		{"quoted with comment", `description: "quoted" # note`, "quoted", false},
+		{"unterminated quote", `description: "never ends`, `"never ends`, true},
+		{"text after quote", `description: "quoted" trailing`, `"quoted" trailing`, true},
+		{"bad escape", `description: "bad \q"`, `"bad \q"`, true},
+		{"literal block", "description: |\n   line one\n     indented\n   line three", "line one\n  indented\nline three\n",
+			false},
+		{"literal block strip", "description: |-\n   line one\n   line two", "line one\nline two", false},
+		{"folded block", "description: >\n   folded\n   text\n\n   para", "folded text\npara\n", false},
+		{"bad block indicator", "description: |x\n   text", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//every line of the header is indented to the same depth as the other keys
+			header := strings.Replace(tt.header, "\n", "\n   ", -1)
+			content := "/* start gist\n   author: someone\n   " + header + "\n   public: false\n   end gist\n*/\n"
+			h := ParseHeader("main.go", []byte(content))
+			if (h.Err() != nil) != tt.wantErr {
+				t.Errorf("ParseHeader() error = %v, wantErr %v", h.Err(), tt.wantErr)
+			}
+			description, _ := h.Get("description")
+			if description.Value != tt.want {
+				t.Errorf("ParseHeader() description = %q, want %q", description.Value, tt.want)
+			}
+			if public, ok := h.Get("public"); !ok || public.Value != "false" {
+				t.Errorf("ParseHeader() public = %q, want the field after the description to be parsed", public.Value)
+			}
+		})
+	}
+}
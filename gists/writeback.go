@@ -0,0 +1,194 @@
+package gists
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//SetField returns content with the header field key set to value, content must be what the header was parsed from.
+// An existing field is rewritten in place, keeping its indentation, comment prefix and the case of its key, while a
+// new field is added after the last field, formatted like it. Line endings are preserved.
+func (h *Header) SetField(content []byte, key, value string) ([]byte, error) {
+	if !h.inFile() && h.Source != SourceSidecar {
+		return nil, fmt.Errorf("%s has no GOGIST header to add %q to", h.File, key)
+	}
+
+	raw := strings.SplitAfter(string(content), "\n")
+	lines := splitLines(string(content))
+	eol := "\n"
+	for _, line := range raw {
+		if e := lineEnding(line); e != "" {
+			eol = e
+			break
+		}
+	}
+
+	format := func(prefix, key string) string {
+		if h.Source == SourceTOML {
+			return prefix + key + " = " + strconv.Quote(value) + eol
+		}
+		return prefix + key + ": " + formatValue(value) + eol
+	}
+
+	if f, ok := h.Get(key); ok {
+		line := lines[f.Line-1]
+		written := line[f.Column-1 : f.Column-1+len(f.Key)]
+		out := append([]string{}, raw[:f.Line-1]...)
+		out = append(out, format(line[:f.Column-1], written))
+		out = append(out, raw[f.EndLine:]...)
+		return []byte(strings.Join(out, "")), nil
+	}
+
+	//after is the index of the line the new field follows, prefix is what precedes its key
+	after, prefix := -1, ""
+	for _, f := range h.Fields {
+		if f.EndLine > after {
+			after = f.EndLine
+			prefix = lines[f.Line-1][:f.Column-1]
+		}
+	}
+	if after < 0 {
+		after, prefix = h.emptyFieldPosition(lines)
+	}
+
+	if after > 0 && lineEnding(raw[after-1]) == "" {
+		raw[after-1] += eol
+	}
+	if after >= len(raw) && raw[len(raw)-1] == "" {
+		after = len(raw) - 1
+	}
+	out := append([]string{}, raw[:after]...)
+	out = append(out, format(prefix, key))
+	out = append(out, raw[after:]...)
+	return []byte(strings.Join(out, "")), nil
+}
+
+//emptyFieldPosition returns the line a field follows, and the text preceding its key, in a header without fields.
+func (h *Header) emptyFieldPosition(lines []string) (int, string) {
+	indent := func(line string) string {
+		return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	}
+
+	switch h.Source {
+	case SourceSidecar:
+		last := len(lines)
+		for last > 0 && strings.TrimSpace(lines[last-1]) == "" {
+			last--
+		}
+		return last, ""
+	case SourceYAML, SourceTOML:
+		//the line of the gist key, or of the [gist] table
+		gist := h.meta[0][0]
+		prefix := indent(lines[gist-1])
+		if h.Source == SourceYAML && !h.allowUnknown {
+			prefix += "  "
+		}
+		return gist, prefix
+	}
+
+	start := h.StartLine - 1
+	c := scanComments(lines, h.syntax)[start]
+	column := c.column + strings.Index(c.text, strings.TrimSpace(c.text))
+	prefix := lines[start][:column-1]
+	if c.opens {
+		//the comment opener shares the line with the start label, line up with the label instead
+		prefix = strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return r
+			}
+			return ' '
+		}, prefix)
+	}
+	return h.EndLine - 1, prefix
+}
+
+//writeFileAtomic replaces the file at path with data, keeping its permissions. The data is written to a temporary
+// file in the same directory first so the file is never left half written.
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+//FormatHeader returns a GOGIST header holding fields, written in the comment syntax of the language of file, see
+// InsertHeader. It returns false if the language of file is not known, a sidecar should be written instead, see
+// FormatSidecar.
+func FormatHeader(file string, fields [][2]string) (string, bool) {
+	lang := lookupLanguage(file)
+	if lang == nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	if len(lang.syntax.line) > 0 {
+		prefix := strings.TrimSpace(lang.syntax.line[0]) + " "
+		b.WriteString(prefix + "start gist\n")
+		for _, f := range fields {
+			b.WriteString(prefix + f[0] + ": " + formatValue(f[1]) + "\n")
+		}
+		b.WriteString(prefix + "end gist\n")
+		return b.String(), true
+	}
+
+	if len(lang.syntax.block) == 0 {
+		return "", false
+	}
+	block := lang.syntax.block[0]
+	indent := strings.Repeat(" ", len(block[0])+1)
+	b.WriteString(block[0] + " start gist\n")
+	for _, f := range fields {
+		b.WriteString(indent + f[0] + ": " + formatValue(f[1]) + "\n")
+	}
+	b.WriteString(indent + "end gist\n" + block[1] + "\n")
+	return b.String(), true
+}
+
+//FormatSidecar returns the content of a sidecar holding fields, see SidecarSuffix
+func FormatSidecar(fields [][2]string) []byte {
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(f[0] + ": " + formatValue(f[1]) + "\n")
+	}
+	return []byte(b.String())
+}
+
+//InsertHeader returns content with header put at its top, separated from the content by a blank line, or directly
+// after the #! line of a script. Stripping the header, see Header.Strip, gives back content.
+func InsertHeader(content, header string) string {
+	if strings.HasPrefix(content, "#!") {
+		if i := strings.Index(content, "\n"); i >= 0 {
+			return content[:i+1] + header + content[i+1:]
+		}
+		return content + "\n" + header
+	}
+	return header + "\n" + content
+}
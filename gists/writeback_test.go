@@ -0,0 +1,160 @@
+package gists
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHeader_SetField(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		key     string
+		want    string
+	}{
+		{"line comments", "main.go",
+			"// start gist\n// description: d\n// end gist\npackage main\n", "id",
+			"// start gist\n// description: d\n// id: abc\n// end gist\npackage main\n"},
+		{"replace multi-line value", "main.go",
+			"/* start gist\n   ID: old\n     continued\n   end gist */\n", "id",
+			"/* start gist\n   ID: abc\n   end gist */\n"},
+		{"no fields after opener", "main.go",
+			"/* start gist\n   end gist\n*/\n", "id",
+			"/* start gist\n   id: abc\n   end gist\n*/\n"},
+		{"crlf", "run.sh",
+			"# start gist\r\n#   public: true\r\n# end gist\r\n", "id",
+			"# start gist\r\n#   public: true\r\n#   id: abc\r\n# end gist\r\n"},
+		{"yaml nested", "post.md",
+			"---\ngist:\n  public: true\ntitle: t\n---\n", "id",
+			"---\ngist:\n  public: true\n  id: abc\ntitle: t\n---\n"},
+		{"yaml nested without fields", "post.md",
+			"---\ngist:\n---\n", "id",
+			"---\ngist:\n  id: abc\n---\n"},
+		{"toml table", "post.md",
+			"+++\n[gist]\npublic = true\n+++\n", "id",
+			"+++\n[gist]\npublic = true\nid = \"abc\"\n+++\n"},
+		{"toml top level", "post.md",
+			"+++\ngist = true\n[params]\nx = 1\n+++\n", "id",
+			"+++\ngist = true\nid = \"abc\"\n[params]\nx = 1\n+++\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := ParseHeader(tt.file, []byte(tt.content))
+			got, err := h.SetField([]byte(tt.content), tt.key, "abc")
+			if err != nil {
+				t.Fatalf("Header.SetField() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Header.SetField() = %q, want %q", got, tt.want)
+			}
+			if field, _ := ParseHeader(tt.file, got).Get(tt.key); field.Value != "abc" {
+				t.Errorf("Header.SetField() wrote %q, which parses as %q", got, field.Value)
+			}
+		})
+	}
+
+	if _, err := ParseHeader("main.go", []byte("package main\n")).SetField([]byte("package main\n"), "id",
+		"abc"); err == nil {
+		t.Errorf("Header.SetField() without a header should fail")
+	}
+}
+
+func TestGistParser_WriteID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "writeback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	inFile := write("main.go", "// start gist\n// description: d\n// end gist\npackage main\n")
+	data := write("data.json", "{}\n")
+	sidecar := write("data.json"+SidecarSuffix, "description: d")
+
+	tests := []struct {
+		name   string
+		path   string
+		target string
+		want   string
+	}{
+		{"in file", inFile, inFile, "// start gist\n// description: d\n// id: abc\n// url: https://gist.github.com/abc\n" +
+			"// end gist\npackage main\n"},
+		{"sidecar", data, sidecar, "description: d\nid: abc\nurl: https://gist.github.com/abc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &GistParser{Filepath: tt.path}
+			for i := 0; i < 2; i++ {
+				if err := g.WriteID("abc", "https://gist.github.com/abc"); err != nil {
+					t.Fatalf("GistParser.WriteID() error = %v", err)
+				}
+			}
+			if got := readFile(tt.target); got != tt.want {
+				t.Errorf("GistParser.WriteID() wrote %q, want %q", got, tt.want)
+			}
+			if info, _ := os.Stat(tt.target); info.Mode().Perm() != 0600 {
+				t.Errorf("GistParser.WriteID() changed the mode to %v", info.Mode())
+			}
+			if id, _ := g.GetID(); id != "abc" {
+				t.Errorf("GistParser.GetID() = %q, want abc", id)
+			}
+		})
+	}
+}
+
+func TestFormatHeader(t *testing.T) {
+	fields := [][2]string{{"description", `say "hi": now`}, {"public", "false"}, {"id", "abc"}}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantOk  bool
+	}{
+		{"line comments", "main.go", "package main\n", true},
+		{"block comments", "style.css", "body {}\n", true},
+		{"shebang", "run.sh", "#!/bin/sh\necho hi\n", true},
+		{"shebang and blank line", "run.sh", "#!/bin/sh\n\necho hi\n", true},
+		{"unknown language", "data.unknown", "{}\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, ok := FormatHeader(tt.file, fields)
+			if ok != tt.wantOk {
+				t.Fatalf("FormatHeader() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+
+			content := InsertHeader(tt.content, header)
+			h := ParseHeader(tt.file, []byte(content))
+			for _, f := range fields {
+				if got, _ := h.Get(f[0]); got.Value != f[1] {
+					t.Errorf("FormatHeader() %s = %q, want %q\n%s", f[0], got.Value, f[1], content)
+				}
+			}
+			if got := string(h.Strip([]byte(content))); got != tt.content {
+				t.Errorf("InsertHeader() stripped = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func TestFormatSidecar(t *testing.T) {
+	fields := [][2]string{{"description", "  padded # not a comment"}, {"public", "true"}}
+	h := ParseSidecar("data.json"+SidecarSuffix, FormatSidecar(fields))
+	for _, f := range fields {
+		if got, _ := h.Get(f[0]); got.Value != f[1] {
+			t.Errorf("FormatSidecar() %s = %q, want %q", f[0], got.Value, f[1])
+		}
+	}
+}
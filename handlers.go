@@ -1,12 +1,371 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	mux2 "github.com/gorilla/mux"
+	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/secrets"
+	"io"
 	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
+//maxRequestBytes caps the size of the body of an API request
+const maxRequestBytes = 10 << 20
 
-// CreateGistHandler creates a gist given a request and stores it on gist.github.com
-func CreateGistHandler(w http.ResponseWriter, r *http.Request) {
+//apiFile is a file of an apiGist
+type apiFile struct {
+	Content string `json:"content,omitempty"`
+}
+
+//apiGist is a gist as sent to and returned by the REST API, shaped like a gist of the GitHub API with its files keyed
+// by their names. Description and Public are pointers so an update can leave them out.
+type apiGist struct {
+	ID          string              `json:"id,omitempty"`
+	URL         string              `json:"html_url,omitempty"`
+	Revision    string              `json:"revision,omitempty"`
+	Description *string             `json:"description,omitempty"`
+	Public      *bool               `json:"public,omitempty"`
+	Files       map[string]*apiFile `json:"files,omitempty"`
+
+	//Findings are the possible secrets in a secret gist that was published regardless
+	Findings []secrets.Finding `json:"findings,omitempty"`
+}
+
+//newAPIGist returns the apiGist of gist
+func newAPIGist(gist *gists.GistFile) *apiGist {
+	description, public := gist.Description, gist.Public
+	g := &apiGist{
+		ID:          gist.ID,
+		URL:         gist.URL,
+		Revision:    gist.Revision,
+		Description: &description,
+		Public:      &public,
+		Files:       make(map[string]*apiFile, len(gist.Files)),
+	}
+	for _, f := range gist.Files {
+		g.Files[f.Filename] = &apiFile{Content: f.Content}
+	}
+	return g
+}
+
+//apiError is the body of every error returned by the REST API
+type apiError struct {
+	Error string `json:"error"`
+
+	//Findings are the possible secrets a public gist was refused for
+	Findings []secrets.Finding `json:"findings,omitempty"`
+}
+
+//publishPolicy holds what the REST API checks gists against before they are published, the same visibility settings
+// and secret scanning rules push applies
+type publishPolicy struct {
+	settings *config.Settings
+	scanner  *secrets.Scanner
+}
+
+//loadPublishPolicy returns the publishPolicy of the user's config.SettingsFile and secrets.ConfigFile
+func loadPublishPolicy() (*publishPolicy, error) {
+	settings, err := config.LoadSettings(filepath.Join(config.Dir(), config.SettingsFile))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := secrets.LoadConfig(filepath.Join(config.Dir(), secrets.ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	scanner, err := secrets.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &publishPolicy{settings: settings, scanner: scanner}, nil
+}
+
+//review returns the possible secrets in gist. If it may not be published, it returns the error refusing it along
+// with the status to answer with, 403 Forbidden if the settings forbid public gists and 422 Unprocessable Entity for a
+// public gist with possible secrets.
+func (p *publishPolicy) review(gist *gists.GistFile) ([]secrets.Finding, int, error) {
+	if err := checkVisibility(p.settings, gist); err != nil {
+		return nil, http.StatusForbidden, err
+	}
+	findings := p.scanner.ScanGist("gist", nil, gist)
+	if len(findings) > 0 && gist.Public {
+		return findings, http.StatusUnprocessableEntity, secretsError(findings)
+	}
+	return findings, http.StatusOK, nil
+}
+
+//check returns the possible secrets in gist, or writes the error refusing to publish it and returns false
+func (p *publishPolicy) check(w http.ResponseWriter, gist *gists.GistFile) ([]secrets.Finding, bool) {
+	findings, status, err := p.review(gist)
+	if err != nil {
+		writeJSON(w, status, apiError{Error: err.Error(), Findings: findings})
+		return nil, false
+	}
+	return findings, true
+}
+
+//routeAPI registers the REST API on mux. Every endpoint acts on behalf of the user logged in to the browser session of
+// the request, or of the token in its Authorization header, see requireSession.
+//
+//	GET    /api/gists       lists the gists of the user, without the content of their files
+//	POST   /api/gists       creates a gist
+//	GET    /api/gists/{id}  returns a gist
+//	PATCH  /api/gists/{id}  changes the description, visibility or files of a gist
+//	DELETE /api/gists/{id}  deletes a gist
+func routeAPI(mux *mux2.Router, policy *publishPolicy, sessions *auth.Sessions) {
+	session := func(next http.HandlerFunc) http.HandlerFunc {
+		return requireSession(sessions, next)
+	}
+	mux.Methods(http.MethodGet).Path("/api/gists").HandlerFunc(session(ListGistsHandler))
+	mux.Methods(http.MethodPost).Path("/api/gists").HandlerFunc(session(CreateGistHandler(policy)))
+	mux.Methods(http.MethodGet).Path("/api/gists/{id:[0-9A-Za-z]+}").HandlerFunc(session(RetrieveGistHandler))
+	mux.Methods(http.MethodPatch).Path("/api/gists/{id:[0-9A-Za-z]+}").HandlerFunc(session(UpdateGistHandler(policy)))
+	mux.Methods(http.MethodDelete).Path("/api/gists/{id:[0-9A-Za-z]+}").HandlerFunc(session(DeleteGistHandler))
+}
+
+//requireSession refuses requests with 401 Unauthorized unless they carry a token in their Authorization header, as
+// `token <token>` or `Bearer <token>`, or come from a browser that is logged in. The token is set on the context of
+// the request, so next acts on behalf of its user rather than of auth.Session.
+func requireSession(sessions *auth.Sessions, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			session, err := sessions.Current(r)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, fmt.Errorf("not logged in, log in at / or send a token with "+
+					"the gist scope in the Authorization header"))
+				return
+			}
+			token = session.AccessToken
+		}
+		next(w, r.WithContext(auth.WithToken(r.Context(), token)))
+	}
+}
+
+//bearerToken returns the token in the Authorization header of r, or "" if there is none
+func bearerToken(r *http.Request) string {
+	fields := strings.Fields(r.Header.Get("Authorization"))
+	if len(fields) != 2 || !(strings.EqualFold(fields[0], "token") || strings.EqualFold(fields[0], "bearer")) {
+		return ""
+	}
+	return fields[1]
+}
+
+// CreateGistHandler creates a gist given a request and stores it on gist.github.com. The request body is an apiGist
+// with at least one file, its visibility defaults to config.Settings.Public. Public gists are refused if the settings
+// forbid them or they hold possible secrets.
+func CreateGistHandler(policy *publishPolicy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req apiGist
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(req.Files) == 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("a gist needs at least one file"))
+			return
+		}
+
+		gist := &gists.GistFile{Public: policy.settings.Public}
+		if req.Description != nil {
+			gist.Description = *req.Description
+		}
+		if req.Public != nil {
+			gist.Public = *req.Public
+		}
+		if err := setFiles(gist, req.Files); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
 
+		findings, ok := policy.check(w, gist)
+		if !ok {
+			return
+		}
+		pushed, err := pushGistContext(r.Context(), gist, nil)
+		if err != nil {
+			upstreamError(w, err)
+			return
+		}
+		gist.ID, gist.URL, gist.Revision = pushed.ID, pushed.URL, pushed.Revision
+		created := newAPIGist(gist)
+		created.Findings = findings
+		writeJSON(w, http.StatusCreated, created)
+	}
 }
 
+// ListGistsHandler lists the gists of the logged in user, their files are named but their content is not included
+func ListGistsHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := gists.ListContext(r.Context())
+	if err != nil {
+		upstreamError(w, err)
+		return
+	}
+	listed := make([]*apiGist, 0, len(list))
+	for _, g := range list {
+		listed = append(listed, newAPIGist(g))
+	}
+	writeJSON(w, http.StatusOK, listed)
+}
+
+// RetrieveGistHandler returns the gist identified by the id in the path
+func RetrieveGistHandler(w http.ResponseWriter, r *http.Request) {
+	gist, err := retrieveGist(r.Context(), mux2.Vars(r)["id"])
+	if err != nil {
+		upstreamError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIGist(gist))
+}
+
+// UpdateGistHandler changes the gist identified by the id in the path. The request body is an apiGist whose
+// description, visibility and files replace those of the gist, files it leaves out are kept. GitHub cannot change the
+// visibility of a gist, so asking to is refused with 409 Conflict.
+func UpdateGistHandler(policy *publishPolicy) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req apiGist
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		gist, err := retrieveGist(r.Context(), mux2.Vars(r)["id"])
+		if err != nil {
+			upstreamError(w, err)
+			return
+		}
+		remote := *gist
+		if req.Description != nil {
+			gist.Description = *req.Description
+		}
+		if req.Public != nil {
+			gist.Public = *req.Public
+		}
+		if err := setFiles(gist, req.Files); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		findings, ok := policy.check(w, gist)
+		if !ok {
+			return
+		}
+		pushed, err := pushGistContext(r.Context(), gist, &remote)
+		if err != nil {
+			upstreamError(w, err)
+			return
+		}
+		gist.URL, gist.Revision = pushed.URL, pushed.Revision
+		updated := newAPIGist(gist)
+		updated.Findings = findings
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+// DeleteGistHandler deletes the gist identified by the id in the path
+func DeleteGistHandler(w http.ResponseWriter, r *http.Request) {
+	resp, err := (&gists.GistFile{}).DeleteContext(r.Context(), mux2.Vars(r)["id"])
+	if err != nil {
+		upstreamError(w, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		upstreamError(w, &statusError{code: resp.StatusCode, status: resp.Status})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//retrieveGist retrieves the gist with the given id, a response with an unexpected status is a statusError
+func retrieveGist(ctx context.Context, id string) (*gists.GistFile, error) {
+	gist := &gists.GistFile{}
+	resp, err := gist.RetrieveContext(ctx, id)
+	if err != nil && resp != nil {
+		return nil, &statusError{code: resp.StatusCode, status: err.Error()}
+	}
+	return gist, err
+}
+
+//setFiles adds files to gist, replacing the content of the files it already has, and keeps its files sorted by name
+func setFiles(gist *gists.GistFile, files map[string]*apiFile) error {
+	for name, f := range files {
+		if err := gists.ValidateFilename(name); err != nil {
+			return err
+		}
+		if f == nil {
+			return fmt.Errorf("removing %s is not supported", name)
+		}
+		if strings.TrimSpace(f.Content) == "" {
+			return fmt.Errorf("the content of %s is empty", name)
+		}
+
+		replaced := false
+		for i := range gist.Files {
+			if gist.Files[i].Filename == name {
+				gist.Files[i].Content, replaced = f.Content, true
+			}
+		}
+		if !replaced {
+			gist.Files = append(gist.Files, gists.GistFileBody{Filename: name, Content: f.Content})
+		}
+	}
+	sort.Slice(gist.Files, func(i, j int) bool { return gist.Files[i].Filename < gist.Files[j].Filename })
+	return nil
+}
+
+//readJSON decodes the body of r into v. Bodies larger than maxRequestBytes, with unknown fields or trailing data are
+// an error.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body -> %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("invalid request body -> unexpected data after the JSON value")
+	}
+	return nil
+}
+
+//writeJSON writes v as the JSON body of a response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+//writeError writes err as the apiError body of a response with the given status
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+//upstreamError writes the error of a request to the GitHub API that failed. Statuses the client can act on, e.g. 404
+// for a gist that does not exist, are passed on, changes of visibility are a 409 Conflict and anything else is a 502
+// Bad Gateway.
+func upstreamError(w http.ResponseWriter, err error) {
+	writeError(w, upstreamStatus(err), err)
+}
+
+//upstreamStatus returns the status upstreamError answers err with
+func upstreamStatus(err error) int {
+	switch e := err.(type) {
+	case *statusError:
+		switch e.code {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity:
+			return e.code
+		}
+	default:
+		if err == gists.ErrMadePublic || err == gists.ErrMadeSecret {
+			return http.StatusConflict
+		}
+	}
+	return http.StatusBadGateway
+}
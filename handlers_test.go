@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	mux2 "github.com/gorilla/mux"
+	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/secrets"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//roundTripFunc lets a function stand in for auth.Session.Client's transport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+//fakeGitHub has auth.Session answer from a GitHub API holding a single secret gist, abc, that only the user of token
+// can see and has starred. It returns the function restoring the session.
+func fakeGitHub(token string) func() {
+	session := auth.Session
+	auth.Session.AccessToken = ""
+	auth.Session.Client = &http.Client{Transport: &auth.TokenTransport{Base: roundTripFunc(func(r *http.Request) (
+		*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		if r.Header.Get("Authorization") != "token "+token {
+			recorder.WriteHeader(http.StatusUnauthorized)
+			return recorder.Result(), nil
+		}
+		gist := map[string]interface{}{
+			"id":          "abc",
+			"html_url":    "https://gist.github.com/abc",
+			"description": "notes",
+			"public":      false,
+			"files":       map[string]interface{}{"a.txt": map[string]string{"content": "a"}},
+			"history":     []map[string]string{{"version": "v1"}},
+		}
+		switch {
+		case r.Method == http.MethodPost:
+			gist["id"], gist["html_url"] = "new", "https://gist.github.com/new"
+			recorder.WriteHeader(http.StatusCreated)
+		case r.URL.Path == "/gists" || r.URL.Path == "/gists/starred":
+			json.NewEncoder(recorder).Encode([]interface{}{gist})
+			return recorder.Result(), nil
+		case r.URL.Path == "/gists/abc/star":
+			recorder.WriteHeader(http.StatusNoContent)
+			return recorder.Result(), nil
+		case !strings.HasSuffix(r.URL.Path, "/gists/abc"):
+			recorder.WriteHeader(http.StatusNotFound)
+			return recorder.Result(), nil
+		case r.Method == http.MethodDelete:
+			recorder.WriteHeader(http.StatusNoContent)
+			return recorder.Result(), nil
+		}
+		json.NewEncoder(recorder).Encode(gist)
+		return recorder.Result(), nil
+	})}}
+	return func() { auth.Session = session }
+}
+
+//loggedIn returns Sessions holding the session of a browser logged in with token, and its cookie
+func loggedIn(t *testing.T, token string) (*auth.Sessions, *http.Cookie) {
+	sessions := auth.NewSessions(auth.NewMemoryStore())
+	recorder := httptest.NewRecorder()
+	if _, err := sessions.Start(recorder, httptest.NewRequest(http.MethodGet, "/", nil), token, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	return sessions, recorder.Result().Cookies()[0]
+}
+
+func Test_routeAPI(t *testing.T) {
+	defer fakeGitHub("alice-token")()
+	scanner, err := secrets.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, cookie := loggedIn(t, "alice-token")
+	mux := mux2.NewRouter()
+	routeAPI(mux, &publishPolicy{settings: &config.Settings{ForbidPublic: true}, scanner: scanner}, sessions)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"create", http.MethodPost, "/api/gists", `{"files": {"b.txt": {"content": "b"}}}`, http.StatusCreated,
+			`"id":"new"`},
+		{"create without files", http.MethodPost, "/api/gists", `{"description": "d"}`, http.StatusBadRequest,
+			`"error":"a gist needs at least one file"`},
+		{"create with bad name", http.MethodPost, "/api/gists", `{"files": {"../b": {"content": "b"}}}`,
+			http.StatusBadRequest, `may not contain /`},
+		{"create with unknown field", http.MethodPost, "/api/gists", `{"file": {}}`, http.StatusBadRequest,
+			`unknown field`},
+		{"create public", http.MethodPost, "/api/gists", `{"public": true, "files": {"b.txt": {"content": "b"}}}`,
+			http.StatusForbidden, `forbid_public`},
+		{"retrieve", http.MethodGet, "/api/gists/abc", "", http.StatusOK, `"a.txt":{"content":"a"}`},
+		{"retrieve missing", http.MethodGet, "/api/gists/def", "", http.StatusNotFound, `"error"`},
+		{"update", http.MethodPatch, "/api/gists/abc", `{"files": {"b.txt": {"content": "b"}}}`, http.StatusOK,
+			`"files":{"a.txt":{"content":"a"},"b.txt":{"content":"b"}}`},
+		{"update removing a file", http.MethodPatch, "/api/gists/abc", `{"files": {"a.txt": null}}`,
+			http.StatusBadRequest, `removing a.txt is not supported`},
+		{"delete", http.MethodDelete, "/api/gists/abc", "", http.StatusNoContent, ""},
+		{"delete missing", http.MethodDelete, "/api/gists/def", "", http.StatusNotFound, `"error":"404 Not Found"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.AddCookie(cookie)
+			mux.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s = %s, want it to contain %s", tt.method, tt.path, recorder.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_requireSession(t *testing.T) {
+	defer fakeGitHub("alice-token")()
+	sessions, cookie := loggedIn(t, "alice-token")
+	mux := mux2.NewRouter()
+	routeAPI(mux, &publishPolicy{settings: &config.Settings{}}, sessions)
+
+	tests := []struct {
+		name          string
+		cookie        *http.Cookie
+		authorization string
+		wantStatus    int
+	}{
+		{"session cookie", cookie, "", http.StatusOK},
+		{"token header", nil, "token alice-token", http.StatusOK},
+		{"bearer header", nil, "Bearer alice-token", http.StatusOK},
+		{"token of another user", nil, "token bob-token", http.StatusUnauthorized},
+		{"unknown session", &http.Cookie{Name: auth.CookieName, Value: "forged"}, "", http.StatusUnauthorized},
+		{"logged out", nil, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/gists/abc", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("GET /api/gists/abc = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
+
+func Test_routeAPI_loggedOut(t *testing.T) {
+	session := auth.Session
+	defer func() { auth.Session = session }()
+	auth.Session.AccessToken = "token"
+
+	mux := mux2.NewRouter()
+	routeAPI(mux, &publishPolicy{settings: &config.Settings{}}, auth.NewSessions(auth.NewMemoryStore()))
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/gists", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/gists = %d, want %d, the token of the server is not shared", recorder.Code,
+			http.StatusUnauthorized)
+	}
+}
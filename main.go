@@ -1,19 +1,24 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	mux2 "github.com/gorilla/mux"
 	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/cache"
 	"github.com/martinomburajr/gist/config"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 )
 
 func main() {
 	//flags
 	//file := flag.String("file", "", "file to be sent to gist")
-	//fileOverride := flag.String("setfile", "", "overrides the current file name for a new one. The filename provided by this flag will be reflected on the gists.github.com website")
 	//description := flag.String("description", "", "sets the description of the gist")
 	//isPublic := flag.Bool("pub", true, "set as public gist. This is set to true by default")
 
@@ -21,26 +26,88 @@ func main() {
 	//	//check file exists
 	//}
 
-	//@todo change mux2 alias to original mux alias
-	mux := mux2.NewRouter()
+	sessionStore := flag.String("sessions", "file", "where the login server keeps the sessions of logged in "+
+		"browsers, file or memory")
+	uiDir := flag.String("ui", config.UIDir(), "directory whose templates and static/ files override those of the "+
+		"browser UI")
+	daemon := flag.Bool("daemon", false, "keep serving the browser UI and the REST API rather than exiting once "+
+		"logged in")
+	addr := flag.String("addr", fmt.Sprintf("127.0.0.1:%d", config.PORT), "address the server listens on with "+
+		"-daemon, only this machine can reach it unless e.g. :8089 is given to listen on every interface")
+	flag.Usage = usage
+	flag.Parse()
 
-	authTemplate := template.Must(template.ParseFiles("public/oauth.html"))
-	mux.Methods(http.MethodGet).Path("/").HandlerFunc(LoginHandler(authTemplate))
-	mux.Methods(http.MethodGet).Path("/auth/github/callback").HandlerFunc(auth.RedirectHandler)
+	setupSession()
 
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", config.PORT), mux))
+	if flag.NArg() > 0 {
+		name := flag.Arg(0)
+		if err := runCommand(name, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "gist %s: %s\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	opts := serverOptions{Addr: *addr, Sessions: *sessionStore, UI: *uiDir}
+	var err error
+	if *daemon {
+		opts.ready = func(url string) { log.Printf("serving on %s", url) }
+		err = serve(ctx, opts)
+	} else {
+		opts.ready = func(url string) { fmt.Printf("Open %s in your browser to log in to GitHub\n", url) }
+		err = login(ctx, opts, filepath.Join(config.Dir(), config.TokenFile), loginTimeout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gist: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+//setupSession prepares auth.Session for talking to the GitHub API. The AccessToken is taken from the TokenEnv
+// environment variable when set, and otherwise from the config.TokenFile saved by logging in. GET requests are
+// revalidated against the on-disk response cache.
+func setupSession() {
+	if token := os.Getenv(auth.TokenEnv); token != "" {
+		auth.Session.AccessToken = token
+	} else if token, err := auth.LoadToken(filepath.Join(config.Dir(), config.TokenFile)); err == nil {
+		auth.Session.AccessToken = token
+	} else if !os.IsNotExist(err) {
+		log.Print(err)
+	}
+
+	auth.Session.Client = &http.Client{
+		Transport: &auth.TokenTransport{
+			Base: &cache.Transport{
+				Store: cache.NewStore(config.CacheDir(), config.CacheMaxBytes),
+			},
+		},
+	}
 }
 
 // LoginHandler handles the logging in of a user.
 // It will open a simple OAuth Page on a browser that will enable the OAuth flow to begin.
-// A successful login returns a valid OAuth AccessToken that is stored in the auth.Session variable.
-func LoginHandler(authTemplate *template.Template) func(w http.ResponseWriter, r *http.Request) {
+// A successful login returns a valid OAuth AccessToken that is kept in the auth.WebSession of the browser, and the
+// page then shows who is logged in. The login link carries a state bound to the browser, see auth.LoginURL.
+func LoginHandler(authTemplate *template.Template, sessions *auth.Sessions) func(w http.ResponseWriter,
+	r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := authTemplate.Execute(w, struct {
+		data := struct {
 			URLL string
-		}{
-			URLL: auth.AuthURL,
-		}); err != nil {
+			User string
+		}{}
+		if session, err := sessions.Current(r); err == nil {
+			data.User = session.User
+		} else {
+			loginURL, err := auth.LoginURL(w, r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data.URLL = loginURL
+		}
+		if err := authTemplate.Execute(w, data); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
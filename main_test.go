@@ -1,2 +1,178 @@
 package main
 
+import (
+	"filippo.io/age"
+	"fmt"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/crypt"
+	"github.com/martinomburajr/gist/manifest"
+	"github.com/martinomburajr/gist/secrets"
+	"github.com/martinomburajr/gist/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_planPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	created := write("new.go", "// start gist\n// description: new\n// public: true\n// end gist\npackage main\n")
+	updated := write("old.go", "// start gist\n// description: old\n// id: abc\n// end gist\npackage main\n")
+	unchanged := write("same.go", "// start gist\n// description: same\n// end gist\npackage main\n")
+	invalid := write("bad.go", "// start gist\n// public: maybe\n// end gist\n")
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same := utils.ScanFile(unchanged, nil)
+	same.Gist.ID = "def"
+	hash, err := manifest.Hash(same.Gist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set(unchanged, &manifest.Entry{GistID: "def", Hash: hash})
+	m.Set(filepath.Join(dir, "gone.go"), &manifest.Entry{GistID: "ghi"})
+
+	var results []*utils.ScanResult
+	for _, path := range []string{created, updated, unchanged, invalid} {
+		results = append(results, utils.ScanFile(path, nil))
+	}
+
+	tests := []struct {
+		name     string
+		settings *config.Settings
+		prune    bool
+		want     []string
+	}{
+		{"report missing", nil, false, []string{actionCreate, actionUpdate, actionUnchanged, actionSkip, actionMissing}},
+		{"prune", nil, true, []string{actionCreate, actionUpdate, actionUnchanged, actionSkip, actionDelete}},
+		{"forbid public", &config.Settings{ForbidPublic: true}, false,
+			[]string{actionSkip, actionUpdate, actionUnchanged, actionSkip, actionMissing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := planPush(results, m, nil, tt.settings, nil, tt.prune, true)
+			if err != nil {
+				t.Fatalf("planPush() error = %v", err)
+			}
+			if len(steps) != len(tt.want) {
+				t.Fatalf("planPush() = %d steps, want %d", len(steps), len(tt.want))
+			}
+			for i, step := range steps {
+				if step.Action != tt.want[i] {
+					t.Errorf("planPush() step %d for %s = %s, want %s", i, step.Path, step.Action, tt.want[i])
+				}
+			}
+			if steps[1].Method != "PATCH" || steps[1].URL != "https://api.github.com/gists/abc" {
+				t.Errorf("planPush() update = %s %s, want PATCH https://api.github.com/gists/abc", steps[1].Method,
+					steps[1].URL)
+			}
+			g := steps[0].Gist
+			if steps[0].Action == actionCreate &&
+				(g == nil || g.Size != len(results[0].Gist.Files[0].Content) || len(g.Body) == 0) {
+				t.Errorf("planPush() create gist = %+v, want its size and body", g)
+			}
+		})
+	}
+}
+
+func Test_planPush_encrypted(t *testing.T) {
+	dir := t.TempDir()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &crypt.Keys{Profiles: map[string][]age.Recipient{"default": {identity.Recipient()}}}
+	scanner, err := secrets.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := "ghp_" + strings.Repeat("a1B2", 9)
+
+	tests := []struct {
+		name         string
+		header       string
+		body         string
+		wantAction   string
+		wantFindings int
+	}{
+		{"secret in the encrypted content", "// public: true\n// description: notes\n",
+			"// " + token + "\n", actionCreate, 0},
+		{"secret in the description of a public gist", "// public: true\n// description: " + token + "\n", "",
+			actionSkip, 1},
+		{"secret in the description of a secret gist", "// description: " + token + "\n", "", actionCreate, 1},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("file%d.go", i))
+			content := "// start gist\n// encrypt: true\n" + tt.header + "// end gist\npackage main\n" + tt.body
+			if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			result := utils.ScanFile(path, nil)
+			steps, err := planPush([]*utils.ScanResult{result}, m, scanner, nil, keys, false, false)
+			if err != nil {
+				t.Fatalf("planPush() error = %v", err)
+			}
+			if steps[0].Action != tt.wantAction || len(steps[0].Findings) != tt.wantFindings {
+				t.Errorf("planPush() = %s with %d findings (%s), want %s with %d", steps[0].Action,
+					len(steps[0].Findings), steps[0].Reason, tt.wantAction, tt.wantFindings)
+			}
+		})
+	}
+}
+
+func Test_sameProject(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/.git", "a/sub", "a/vendor/.git", "b/.git", "plain", "other"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte("package main\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	a, sub, vendor, b := path("a/x.go"), path("a/sub/y.go"), path("a/vendor/v.go"), path("b/z.go")
+	plain, other := path("plain/p.go"), path("other/o.go")
+
+	tests := []struct {
+		name    string
+		paths   []string
+		wantErr bool
+	}{
+		{"one project", []string{a, sub, filepath.Join(dir, "a")}, false},
+		{"two projects", []string{a, b}, true},
+		{"nested project", []string{filepath.Join(dir, "a"), vendor}, true},
+		{"no project", []string{plain, other}, false},
+		{"no project then a project", []string{plain, b}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sameProject(tt.paths); (err != nil) != tt.wantErr {
+				t.Errorf("sameProject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/gists"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//FileName is the name of the manifest within the config.DirName directory of a project
+const FileName = "manifest.json"
+
+//Version is written into every manifest so the format can change without misreading older files. Version 2 renamed
+// pushed_at to synced_at, see Load.
+const Version = 2
+
+//Entry records the gist a local file was last pushed as, or pulled from.
+type Entry struct {
+	GistID string `json:"gist_id"`
+	URL    string `json:"url"`
+
+	//Files are the names of the files of the gist, more than one when the file has several regions
+	Files []string `json:"files"`
+
+	//Hash is the hash of the gist as it was last pushed or pulled, see Hash. A file whose gist hashes differently has
+	// been edited since.
+	Hash string `json:"hash"`
+
+	//Revision is the SHA of the gist's latest revision on GitHub after the last push or pull
+	Revision string `json:"revision"`
+
+	//SyncedAt is when the file was last pushed or pulled
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+//Manifest maps the files of a project to the gists they were pushed as. It lives in .gist/manifest.json at the root
+// of the project, so files can be kept in sync with their gists without writing ids into them and re-runs of push
+// only touch files that changed. Paths are slash separated and relative to Root.
+type Manifest struct {
+	Version int               `json:"version"`
+	Files   map[string]*Entry `json:"files"`
+
+	//Root is the project directory holding the manifest
+	Root string `json:"-"`
+}
+
+//FindRoot returns the project root for dir: the closest directory, starting at dir and walking up, that holds a
+// manifest or a .git directory. dir itself is returned if there is neither.
+func FindRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for current := abs; ; {
+		if _, err := os.Stat(filepath.Join(current, config.DirName, FileName)); err == nil {
+			return current, nil
+		}
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return abs, nil
+		}
+		current = parent
+	}
+}
+
+//Path returns the path of the manifest of the project rooted at root
+func Path(root string) string {
+	return filepath.Join(root, config.DirName, FileName)
+}
+
+//Load reads the manifest of the project rooted at root. A project without a manifest gets an empty one. Manifests of
+// an older Version are upgraded as they are read.
+func Load(root string) (*Manifest, error) {
+	m := &Manifest{Version: Version, Files: map[string]*Entry{}, Root: root}
+
+	data, err := ioutil.ReadFile(Path(root))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read manifest -> %s", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("could not parse manifest %s -> %s", Path(root), err)
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("manifest %s has version %d, this gist understands up to %d", Path(root), m.Version,
+			Version)
+	}
+	if m.Files == nil {
+		m.Files = map[string]*Entry{}
+	}
+	if m.Version < 2 {
+		var legacy struct {
+			Files map[string]struct {
+				PushedAt time.Time `json:"pushed_at"`
+			} `json:"files"`
+		}
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, fmt.Errorf("could not parse manifest %s -> %s", Path(root), err)
+		}
+		for key, e := range legacy.Files {
+			if entry := m.Files[key]; entry != nil && entry.SyncedAt.IsZero() {
+				entry.SyncedAt = e.PushedAt
+			}
+		}
+	}
+	m.Version = Version
+	return m, nil
+}
+
+//Save writes the manifest atomically, creating the config.DirName directory if needed.
+func (m *Manifest) Save() error {
+	dir := filepath.Dir(Path(m.Root))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create manifest directory -> %s", err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+FileName+"-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), Path(m.Root)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+//Key returns the key path is recorded under, its slash separated path relative to Root. Paths outside Root are
+// recorded by their absolute path.
+func (m *Manifest) Key(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(m.Root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(abs), nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+//Get returns the entry of the file at path, or nil if it has not been pushed
+func (m *Manifest) Get(path string) *Entry {
+	key, err := m.Key(path)
+	if err != nil {
+		return nil
+	}
+	return m.Files[key]
+}
+
+//Set records entry for the file at path
+func (m *Manifest) Set(path string, entry *Entry) error {
+	key, err := m.Key(path)
+	if err != nil {
+		return err
+	}
+	m.Files[key] = entry
+	return nil
+}
+
+//File returns the path of the file recorded under key, the reverse of Key
+func (m *Manifest) File(key string) string {
+	path := filepath.FromSlash(key)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.Root, path)
+	}
+	return path
+}
+
+//Remove forgets the file recorded under key
+func (m *Manifest) Remove(key string) {
+	delete(m.Files, key)
+}
+
+//Missing returns the keys, in lexical order, of recorded files that no longer exist on disk
+func (m *Manifest) Missing() []string {
+	missing := make([]string, 0)
+	for key := range m.Files {
+		if _, err := os.Stat(m.File(key)); os.IsNotExist(err) {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+//Hash returns a hash of everything push sends for gist: its description, visibility, file names and contents. Two
+// gists with the same hash need not be pushed twice.
+func Hash(gist *gists.GistFile) (string, error) {
+	data, err := json.Marshal(gist)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+//Unchanged reports whether the entry already records gist as pushed under the given id
+func (e *Entry) Unchanged(id, hash string) bool {
+	return e != nil && e.GistID == id && e.Hash == hash
+}
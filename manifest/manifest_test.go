@@ -0,0 +1,148 @@
+package manifest
+
+import (
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/gists"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := filepath.Join(dir, "project")
+	nested := filepath.Join(project, "snippets", "go")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(project, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	lone := filepath.Join(dir, "lone")
+	if err := os.MkdirAll(lone, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{"nested", nested, project},
+		{"root", project, project},
+		{"no project", lone, lone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindRoot(tt.dir)
+			if err != nil {
+				t.Fatalf("FindRoot() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FindRoot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifest_SaveLoad(t *testing.T) {
+	root, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	kept := filepath.Join(root, "a", "kept.go")
+	if err := os.MkdirAll(filepath.Dir(kept), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(kept, []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(m.Files) != 0 {
+		t.Fatalf("Load() of a project without a manifest = %v", m.Files)
+	}
+
+	entry := &Entry{GistID: "abc", URL: "https://gist.github.com/abc", Files: []string{"kept.go"}, Hash: "sha256:1",
+		Revision: "r1", SyncedAt: time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := m.Set(kept, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(filepath.Join(root, "gone.go"), &Entry{GistID: "def"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Manifest.Save() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, config.DirName, FileName)); err != nil {
+		t.Fatalf("Manifest.Save() did not write the manifest -> %v", err)
+	}
+
+	loaded, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := loaded.Files["a/kept.go"]; !reflect.DeepEqual(got, entry) {
+		t.Errorf("Load() a/kept.go = %+v, want %+v", got, entry)
+	}
+	if got := loaded.Get(kept); got == nil || got.GistID != "abc" {
+		t.Errorf("Manifest.Get() = %+v", got)
+	}
+	if got := loaded.Missing(); !reflect.DeepEqual(got, []string{"gone.go"}) {
+		t.Errorf("Manifest.Missing() = %v, want [gone.go]", got)
+	}
+}
+
+func TestHash(t *testing.T) {
+	a := &gists.GistFile{Description: "d", Files: []gists.GistFileBody{{Filename: "a.go", Content: "a"}}}
+	b := &gists.GistFile{ID: "abc", Description: "d", Files: []gists.GistFileBody{{Filename: "a.go", Content: "a"}}}
+	c := &gists.GistFile{Description: "d", Files: []gists.GistFileBody{{Filename: "a.go", Content: "b"}}}
+
+	ha, _ := Hash(a)
+	hb, _ := Hash(b)
+	hc, _ := Hash(c)
+	if ha != hb {
+		t.Errorf("Hash() depends on the gist id")
+	}
+	if ha == hc {
+		t.Errorf("Hash() does not depend on the content")
+	}
+
+	entry := &Entry{GistID: "abc", Hash: ha}
+	if !entry.Unchanged("abc", hb) || entry.Unchanged("abc", hc) || (*Entry)(nil).Unchanged("abc", ha) {
+		t.Errorf("Entry.Unchanged() is wrong")
+	}
+}
+
+func TestLoad_version1(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, config.DirName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	v1 := `{"version": 1, "files": {"a.go": {"gist_id": "abc", "pushed_at": "2019-01-02T03:04:05Z"}}}`
+	if err := ioutil.WriteFile(Path(root), []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got := m.Files["a.go"]; got == nil || !got.SyncedAt.Equal(want) || m.Version != Version {
+		t.Errorf("Load() of a version 1 manifest = %+v, version %d, want synced at %s", got, m.Version, want)
+	}
+}
@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"fmt"
+	"github.com/martinomburajr/gist/gists"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//builtinRedactors are the patterns every Scanner redacts besides secrets, they are not secrets so they are never
+// reported by Scan
+var builtinRedactors = []detector{
+	{name: "email", pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{name: "private-ip", pattern: regexp.MustCompile(`\b(?:10(?:\.\d{1,3}){3}|192\.168(?:\.\d{1,3}){2}|` +
+		`172\.(?:1[6-9]|2\d|3[01])(?:\.\d{1,3}){2})\b`)},
+}
+
+//Redaction is a substitution made by Scanner.Redact
+type Redaction struct {
+	File string `json:"file"`
+
+	//Line and Column locate the substitution, Line is 0 for substitutions in the description of a gist
+	Line   int `json:"line"`
+	Column int `json:"column"`
+
+	Rule string `json:"rule"`
+
+	//Match is the text replaced, redacted so a secret is never printed, and Replacement what it was replaced with
+	Match       string `json:"match"`
+	Replacement string `json:"replacement"`
+}
+
+//String formats the redaction as file:line:column: rule match -> replacement
+func (r Redaction) String() string {
+	if r.Line == 0 {
+		return fmt.Sprintf("%s: description: %s %s -> %s", r.File, r.Rule, r.Match, r.Replacement)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s %s -> %s", r.File, r.Line, r.Column, r.Rule, r.Match, r.Replacement)
+}
+
+//Redact replaces the secrets found by Scan, and the redact patterns, in the description and files of gist with
+// placeholders, e.g. <redacted:aws-access-key-id>, and returns every substitution made. gist was parsed from the file
+// at path whose content is source, the substitutions are reported at their line within source as by ScanGist. The
+// file itself is left intact.
+func (s *Scanner) Redact(path string, source []byte, gist *gists.GistFile) []Redaction {
+	detectors := append(append([]detector{}, s.detectors...), s.redactors...)
+	lines := strings.Split(string(source), "\n")
+
+	var redactions []Redaction
+	description, found := s.redact(path, gist.Description, detectors)
+	for _, r := range found {
+		r.Line = 0
+		redactions = append(redactions, r)
+	}
+	gist.Description = description
+
+	for i, f := range gist.Files {
+		original := strings.Split(f.Content, "\n")
+		content, found := s.redact(f.Filename, f.Content, detectors)
+		for _, r := range found {
+			if line := sourceLine(lines, original[r.Line-1]); line > 0 {
+				r.File, r.Line = path, line
+			}
+			redactions = append(redactions, r)
+		}
+		gist.Files[i].Content = content
+	}
+	return redactions
+}
+
+//redact returns content with the matches of detectors replaced, and the substitutions made
+func (s *Scanner) redact(file, content string, detectors []detector) (string, []Redaction) {
+	var redactions []Redaction
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		matches := s.match(line, detectors)
+		if len(matches) == 0 {
+			continue
+		}
+
+		//replace from the end of the line so the offsets of the earlier matches stay valid
+		sort.Slice(matches, func(a, b int) bool { return matches[a].start < matches[b].start })
+		redacted := line
+		for j := len(matches) - 1; j >= 0; j-- {
+			m := matches[j]
+			redacted = redacted[:m.start] + m.detector.placeholder() + redacted[m.end:]
+		}
+		for _, m := range matches {
+			redactions = append(redactions, Redaction{
+				File:        file,
+				Line:        i + 1,
+				Column:      m.start + 1,
+				Rule:        m.detector.name,
+				Match:       redact(line[m.start:m.end]),
+				Replacement: m.detector.placeholder(),
+			})
+		}
+		lines[i] = redacted
+	}
+	return strings.Join(lines, "\n"), redactions
+}
+
+//placeholder returns what the secrets found by d are replaced with
+func (d *detector) placeholder() string {
+	if d.replacement != "" {
+		return d.replacement
+	}
+	return "<redacted:" + d.name + ">"
+}
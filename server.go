@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	mux2 "github.com/gorilla/mux"
+	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/config"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	//loginTimeout is how long the login server waits for the user to log in before giving up
+	loginTimeout = 5 * time.Minute
+
+	//shutdownTimeout is how long requests in flight are given to finish once the server shuts down
+	shutdownTimeout = 10 * time.Second
+)
+
+//serverOptions configure the login server and the daemon
+type serverOptions struct {
+	//Addr is the address the daemon listens on, the login server always listens on an ephemeral port of the loopback
+	// interface
+	Addr string
+
+	//Sessions is the kind of store the daemon keeps the sessions of browsers in, see newSessionStore
+	Sessions string
+
+	//UI is the directory overriding the files of the browser UI, see uiFS
+	UI string
+
+	//ready, if set, is called with the URL of the server once it listens
+	ready func(url string)
+}
+
+//newServer returns an http.Server for handler with timeouts, so slow or idle clients cannot hold on to connections
+func newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      2 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
+
+//runServer serves on listener until ctx is done or done is closed, and then shuts srv down gracefully, letting the
+// requests in flight finish within shutdownTimeout. A nil done never closes.
+func runServer(ctx context.Context, srv *http.Server, listener net.Listener, done <-chan struct{}) error {
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Serve(listener) }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	case <-done:
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil {
+		return fmt.Errorf("could not shut down the server -> %s", err)
+	}
+	return nil
+}
+
+//listen listens on addr and points auth.RedirectURI at the port it got, for addresses such as 127.0.0.1:0. It returns
+// the listener and the base URL of the server.
+func listen(addr string) (net.Listener, string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", err
+	}
+	host := "localhost"
+	if ip := listener.Addr().(*net.TCPAddr).IP; ip.IsLoopback() {
+		host = ip.String()
+	}
+	base := fmt.Sprintf("http://%s:%d", host, listener.Addr().(*net.TCPAddr).Port)
+	auth.SetRedirectURI(base + "/auth/github/callback")
+	return listener, base, nil
+}
+
+//login runs the login server on an ephemeral port of the loopback interface. Once the user logs in, their token is
+// saved to tokenFile for the commands to use, see setupSession, and the server shuts down. It returns an error if
+// nobody logs in within timeout or ctx is done first, e.g. on SIGINT.
+func login(ctx context.Context, opts serverOptions, tokenFile string, timeout time.Duration) error {
+	files, err := uiFS(opts.UI)
+	if err != nil {
+		return err
+	}
+	templates, err := parseTemplates(files)
+	if err != nil {
+		return err
+	}
+	listener, base, err := listen("127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	loggedIn := make(chan struct{})
+	var once sync.Once
+	mux := mux2.NewRouter()
+	mux.Methods(http.MethodGet).PathPrefix("/static/").Handler(http.FileServer(http.FS(files)))
+	mux.Methods(http.MethodGet).Path("/").HandlerFunc(LoginHandler(templates.Lookup("oauth.html"),
+		auth.NewSessions(auth.NewMemoryStore())))
+	mux.Methods(http.MethodGet).Path("/auth/github/callback").HandlerFunc(LoginCallbackHandler(templates, tokenFile,
+		func() { once.Do(func() { close(loggedIn) }) }))
+
+	if opts.ready != nil {
+		opts.ready(base + "/")
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := runServer(ctx, newServer(mux), listener, loggedIn); err != nil {
+		return err
+	}
+
+	select {
+	case <-loggedIn:
+		return nil
+	default:
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("nobody logged in within %s", timeout)
+	}
+	return fmt.Errorf("login interrupted")
+}
+
+// LoginCallbackHandler is where GitHub sends the browser back to the login server. Callbacks whose state was not set
+// on the browser by LoginHandler are refused, see auth.VerifyState. It saves the token of the user to tokenFile, shows
+// who logged in and calls done.
+func LoginCallbackHandler(templates *template.Template, tokenFile string, done func()) func(w http.ResponseWriter,
+	r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := auth.VerifyState(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		code := r.FormValue("code")
+		if code == "" {
+			http.Error(w, "missing code", http.StatusBadRequest)
+			return
+		}
+		token, user, err := auth.Exchange(r.Context(), code)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "could not log in with GitHub", http.StatusBadGateway)
+			return
+		}
+		if err := auth.SaveToken(tokenFile, token); err != nil {
+			log.Print(err)
+			http.Error(w, "could not save the token", http.StatusInternalServerError)
+			return
+		}
+		render(w, templates, "login.html", http.StatusOK, page{Title: "Logged in", User: user})
+		done()
+	}
+}
+
+//serve runs the daemon, the long-lived server that hosts the OAuth login flow, the REST API and the browser UI, see
+// routeAPI and routeUI, until ctx is done, e.g. on SIGINT or SIGTERM. Each browser logs in with its own cookie, whose
+// session is kept in the store opts.Sessions names, see newSessionStore.
+func serve(ctx context.Context, opts serverOptions) error {
+	//@todo change mux2 alias to original mux alias
+	mux := mux2.NewRouter()
+
+	sessionStore, err := newSessionStore(opts.Sessions)
+	if err != nil {
+		return err
+	}
+	sessions := auth.NewSessions(sessionStore)
+
+	files, err := uiFS(opts.UI)
+	if err != nil {
+		return err
+	}
+	templates, err := parseTemplates(files)
+	if err != nil {
+		return err
+	}
+	mux.Methods(http.MethodGet).Path("/healthz").HandlerFunc(HealthHandler)
+	mux.Methods(http.MethodGet).PathPrefix("/static/").Handler(http.FileServer(http.FS(files)))
+	mux.Methods(http.MethodGet).Path("/").HandlerFunc(LoginHandler(templates.Lookup("oauth.html"), sessions))
+	mux.Methods(http.MethodGet).Path("/auth/github/callback").HandlerFunc(auth.RedirectHandler(sessions))
+	mux.Methods(http.MethodPost).Path("/logout").HandlerFunc(auth.LogoutHandler(sessions))
+
+	policy, err := loadPublishPolicy()
+	if err != nil {
+		return err
+	}
+	routeAPI(mux, policy, sessions)
+	routeUI(mux, templates, policy, sessions)
+
+	listener, base, err := listen(opts.Addr)
+	if err != nil {
+		return err
+	}
+	if ip := listener.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() {
+		log.Printf("listening on %s, other machines can reach the server", listener.Addr())
+	}
+	if opts.ready != nil {
+		opts.ready(base + "/")
+	}
+	return runServer(ctx, newServer(mux), listener, nil)
+}
+
+// HealthHandler answers 200 OK while the daemon is up, for load balancers and process supervisors
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+//newSessionStore returns the auth.Store called kind, file for an auth.FileStore in config.SessionsDir, which keeps
+// logins across restarts, or memory for an auth.MemoryStore
+func newSessionStore(kind string) (auth.Store, error) {
+	switch kind {
+	case "file":
+		return auth.NewFileStore(config.SessionsDir()), nil
+	case "memory":
+		return auth.NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown session store %q, want file or memory", kind)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/martinomburajr/gist/auth"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+//fakeOAuth has auth.Exchange exchange the code "good" for the token "a" of alice. It returns the function restoring
+// the URLs of auth.
+func fakeOAuth() func() {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token" && r.FormValue("code") == "good":
+			fmt.Fprint(w, `{"access_token": "a"}`)
+		case r.URL.Path == "/token":
+			fmt.Fprint(w, `{"error": "bad_verification_code"}`)
+		case r.URL.Path == "/user" && r.Header.Get("Authorization") == "token a":
+			fmt.Fprint(w, `{"login": "alice"}`)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	tokenURL, userURL, redirectURI := auth.TokenURL, auth.UserURL, auth.RedirectURI
+	auth.TokenURL, auth.UserURL = github.URL+"/token", github.URL+"/user"
+	return func() {
+		github.Close()
+		auth.TokenURL, auth.UserURL = tokenURL, userURL
+		auth.SetRedirectURI(redirectURI)
+	}
+}
+
+func Test_login(t *testing.T) {
+	defer fakeOAuth()()
+
+	tests := []struct {
+		name       string
+		codes      []string
+		forged     bool
+		timeout    time.Duration
+		wantStatus int
+		wantErr    bool
+	}{
+		{"logged in", []string{"good"}, false, time.Minute, http.StatusOK, false},
+		{"bad code then logged in", []string{"bad", "good"}, false, time.Minute, http.StatusOK, false},
+		{"forged state", []string{"good"}, true, 50 * time.Millisecond, http.StatusBadRequest, true},
+		{"timed out", nil, false, 50 * time.Millisecond, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenFile := filepath.Join(t.TempDir(), "token")
+			urls := make(chan string, 1)
+			errs := make(chan error, 1)
+			opts := serverOptions{ready: func(url string) { urls <- url }}
+			go func() { errs <- login(context.Background(), opts, tokenFile, tt.timeout) }()
+
+			base := <-urls
+			if !strings.HasPrefix(auth.RedirectURI, base) {
+				t.Errorf("login() redirects to %s, want the server at %s", auth.RedirectURI, base)
+			}
+			//a connection left open by the client would hold up the shutdown
+			jar, _ := cookiejar.New(nil)
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Jar: jar}
+			var status int
+			for _, code := range tt.codes {
+				state := "forged"
+				if !tt.forged {
+					state = loginState(t, client, base)
+				}
+				resp, err := client.Get(base + "auth/github/callback?code=" + code + "&state=" + state)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				status = resp.StatusCode
+			}
+			if status != tt.wantStatus {
+				t.Errorf("GET /auth/github/callback = %d, want %d", status, tt.wantStatus)
+			}
+
+			select {
+			case err := <-errs:
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("login() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("login() did not shut down")
+			}
+			token, err := ioutil.ReadFile(tokenFile)
+			if tt.wantErr != (err != nil) || (!tt.wantErr && string(token) != "a\n") {
+				t.Errorf("login() saved %q, %v", token, err)
+			}
+		})
+	}
+}
+
+//loginState has client open the login page at base and returns the state of the login it started
+func loginState(t *testing.T, client *http.Client, base string) string {
+	resp, err := client.Get(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	match := regexp.MustCompile(`state=([\w-]+)`).FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("GET / = %s, want a link with a state", body)
+	}
+	return string(match[1])
+}
+
+func Test_login_interrupted(t *testing.T) {
+	defer fakeOAuth()()
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := serverOptions{ready: func(string) { cancel() }}
+	if err := login(ctx, opts, filepath.Join(t.TempDir(), "token"), time.Minute); err == nil {
+		t.Errorf("login() that was interrupted should fail")
+	}
+}
+
+func Test_serve(t *testing.T) {
+	defer fakeOAuth()()
+	ctx, cancel := context.WithCancel(context.Background())
+	urls := make(chan string, 1)
+	errs := make(chan error, 1)
+	opts := serverOptions{Addr: "127.0.0.1:0", Sessions: "memory", ready: func(url string) { urls <- url }}
+	go func() { errs <- serve(ctx, opts) }()
+
+	var base string
+	select {
+	case base = <-urls:
+	case err := <-errs:
+		t.Fatalf("serve() error = %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get(base + "healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"status":"ok"`) {
+		t.Errorf("GET /healthz = %d %s, want 200 OK", resp.StatusCode, body)
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("serve() error = %v, want a graceful shutdown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve() did not shut down")
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	mux2 "github.com/gorilla/mux"
+	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/secrets"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+//highlight returns content as syntax highlighted HTML, the language is guessed from filename and failing that from
+// content
+func highlight(filename, content string) template.HTML {
+	lexer := lexers.Match(filename)
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	var buf bytes.Buffer
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err == nil {
+		err = html.New(html.WithLineNumbers(true)).Format(&buf, styles.Get("github"), iterator)
+	}
+	if err != nil {
+		return template.HTML("<pre>" + template.HTMLEscapeString(content) + "</pre>")
+	}
+	return template.HTML(buf.String())
+}
+
+//page is what every page of the browser UI shows, its title and the user logged in
+type page struct {
+	Title string
+	User  string
+}
+
+//listedGist is a gist on the dashboard
+type listedGist struct {
+	*gists.GistFile
+	Starred bool
+}
+
+//dashboardPage lists the gists of the user
+type dashboardPage struct {
+	page
+	Gists []listedGist
+}
+
+//gistPage shows a gist and its files
+type gistPage struct {
+	page
+	Gist    *gists.GistFile
+	Starred bool
+}
+
+//formFile is a file of the form of an editPage. Existing files of a gist cannot be renamed.
+type formFile struct {
+	Filename string
+	Content  string
+	Existing bool
+}
+
+//editPage is the form creating a gist, or editing the gist with ID
+type editPage struct {
+	page
+	ID          string
+	Description string
+	Public      bool
+	Files       []formFile
+
+	//Preview shows the files highlighted below the form
+	Preview bool
+
+	//Error and Findings are why the gist was not published
+	Error    string
+	Findings []secrets.Finding
+}
+
+//Action returns where the form of the page is posted to
+func (p *editPage) Action() string {
+	if p.ID == "" {
+		return "/gists"
+	}
+	return "/gists/" + p.ID
+}
+
+//uiHandler handles a request of the browser UI on behalf of the user logged in to session
+type uiHandler func(w http.ResponseWriter, r *http.Request, session *auth.WebSession)
+
+//routeUI registers the browser UI on mux. Its pages act on behalf of the user logged in to the browser session of
+// the request, browsers that are not logged in are sent to the login page. Forms are only posted, the SameSite
+// session cookie is not sent along with posts from other sites.
+//
+//	GET  /gists                  lists the gists of the user
+//	GET  /gists/new              the form creating a gist
+//	POST /gists                  creates a gist
+//	GET  /gists/{id}             shows a gist
+//	GET  /gists/{id}/edit        the form editing a gist
+//	POST /gists/{id}             updates a gist
+//	POST /gists/{id}/delete      deletes a gist
+//	POST /gists/{id}/star        stars a gist
+//	POST /gists/{id}/unstar      unstars a gist
+func routeUI(mux *mux2.Router, templates *template.Template, policy *publishPolicy, sessions *auth.Sessions) {
+	session := func(next uiHandler) http.HandlerFunc {
+		return requireBrowserSession(sessions, next)
+	}
+	id := "/gists/{id:[0-9A-Za-z]+}"
+	mux.Methods(http.MethodGet).Path("/gists").HandlerFunc(session(DashboardHandler(templates)))
+	mux.Methods(http.MethodGet).Path("/gists/new").HandlerFunc(session(NewGistHandler(templates, policy)))
+	mux.Methods(http.MethodPost).Path("/gists").HandlerFunc(session(SaveGistHandler(templates, policy)))
+	mux.Methods(http.MethodGet).Path(id).HandlerFunc(session(GistHandler(templates)))
+	mux.Methods(http.MethodGet).Path(id + "/edit").HandlerFunc(session(EditGistHandler(templates)))
+	mux.Methods(http.MethodPost).Path(id).HandlerFunc(session(SaveGistHandler(templates, policy)))
+	mux.Methods(http.MethodPost).Path(id + "/delete").HandlerFunc(session(RemoveGistHandler))
+	mux.Methods(http.MethodPost).Path(id + "/star").HandlerFunc(session(StarGistHandler(true)))
+	mux.Methods(http.MethodPost).Path(id + "/unstar").HandlerFunc(session(StarGistHandler(false)))
+}
+
+//requireBrowserSession redirects browsers that are not logged in to the login page. The token of the session of those
+// that are is set on the context of the request, like requireSession does.
+func requireBrowserSession(sessions *auth.Sessions, next uiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := sessions.Current(r)
+		if err != nil {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		next(w, r.WithContext(auth.WithToken(r.Context(), session.AccessToken)), session)
+	}
+}
+
+// DashboardHandler lists the gists of the logged in user, marking those they starred
+func DashboardHandler(templates *template.Template) uiHandler {
+	return func(w http.ResponseWriter, r *http.Request, session *auth.WebSession) {
+		list, err := gists.ListContext(r.Context())
+		if err != nil {
+			uiError(w, err)
+			return
+		}
+		starred, err := gists.ListStarredContext(r.Context())
+		if err != nil {
+			uiError(w, err)
+			return
+		}
+		isStarred := make(map[string]bool, len(starred))
+		for _, g := range starred {
+			isStarred[g.ID] = true
+		}
+
+		data := dashboardPage{page: page{Title: "Your gists", User: session.User}}
+		for _, g := range list {
+			data.Gists = append(data.Gists, listedGist{GistFile: g, Starred: isStarred[g.ID]})
+		}
+		render(w, templates, "gists.html", http.StatusOK, data)
+	}
+}
+
+// GistHandler shows the gist identified by the id in the path, with its files highlighted
+func GistHandler(templates *template.Template) uiHandler {
+	return func(w http.ResponseWriter, r *http.Request, session *auth.WebSession) {
+		gist, err := retrieveGist(r.Context(), mux2.Vars(r)["id"])
+		if err != nil {
+			uiError(w, err)
+			return
+		}
+		starred, err := gists.StarredContext(r.Context(), gist.ID)
+		if err != nil {
+			uiError(w, err)
+			return
+		}
+		title := gist.Description
+		if title == "" {
+			title = gist.ID
+		}
+		render(w, templates, "gist.html", http.StatusOK, gistPage{page: page{Title: title, User: session.User},
+			Gist: gist, Starred: starred})
+	}
+}
+
+// NewGistHandler shows the form creating a gist, its visibility defaults to config.Settings.Public
+func NewGistHandler(templates *template.Template, policy *publishPolicy) uiHandler {
+	return func(w http.ResponseWriter, r *http.Request, session *auth.WebSession) {
+		render(w, templates, "edit.html", http.StatusOK, &editPage{
+			page:   page{Title: "New gist", User: session.User},
+			Public: policy.settings.Public,
+			Files:  []formFile{{}},
+		})
+	}
+}
+
+// EditGistHandler shows the form editing the gist identified by the id in the path
+func EditGistHandler(templates *template.Template) uiHandler {
+	return func(w http.ResponseWriter, r *http.Request, session *auth.WebSession) {
+		gist, err := retrieveGist(r.Context(), mux2.Vars(r)["id"])
+		if err != nil {
+			uiError(w, err)
+			return
+		}
+		data := &editPage{
+			page:        page{Title: "Edit " + gist.ID, User: session.User},
+			ID:          gist.ID,
+			Description: gist.Description,
+			Public:      gist.Public,
+		}
+		for _, f := range gist.Files {
+			data.Files = append(data.Files, formFile{Filename: f.Filename, Content: f.Content, Existing: true})
+		}
+		render(w, templates, "edit.html", http.StatusOK, data)
+	}
+}
+
+// SaveGistHandler handles the form of NewGistHandler and EditGistHandler. Its action button either previews the
+// files, adds a file to the form or saves the gist, creating it unless the path identifies one. Files of the gist left
+// out of the form are kept, and the visibility of an existing gist cannot be changed. Gists are checked against the
+// visibility settings and secret scanning rules as by the REST API, a gist that is refused is shown again in the form
+// along with why.
+func SaveGistHandler(templates *template.Template, policy *publishPolicy) uiHandler {
+	return func(w http.ResponseWriter, r *http.Request, session *auth.WebSession) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gist := &gists.GistFile{}
+		if id := mux2.Vars(r)["id"]; id != "" {
+			var err error
+			if gist, err = retrieveGist(r.Context(), id); err != nil {
+				uiError(w, err)
+				return
+			}
+		} else {
+			gist.Public = r.FormValue("public") != ""
+		}
+		gist.Description = r.FormValue("description")
+
+		data := &editPage{
+			page:        page{Title: "New gist", User: session.User},
+			ID:          gist.ID,
+			Description: gist.Description,
+			Public:      gist.Public,
+			Files:       formFiles(r, gist),
+		}
+		if gist.ID != "" {
+			data.Title = "Edit " + gist.ID
+		}
+
+		switch r.FormValue("action") {
+		case "add":
+			data.Files = append(data.Files, formFile{})
+			render(w, templates, "edit.html", http.StatusOK, data)
+			return
+		case "preview":
+			data.Preview = true
+			render(w, templates, "edit.html", http.StatusOK, data)
+			return
+		}
+
+		files := make(map[string]*apiFile, len(data.Files))
+		for _, f := range data.Files {
+			files[f.Filename] = &apiFile{Content: f.Content}
+		}
+		if len(files) == 0 {
+			data.Error = "a gist needs at least one file"
+			render(w, templates, "edit.html", http.StatusBadRequest, data)
+			return
+		}
+		if err := setFiles(gist, files); err != nil {
+			data.Error = err.Error()
+			render(w, templates, "edit.html", http.StatusBadRequest, data)
+			return
+		}
+		if findings, status, err := policy.review(gist); err != nil {
+			data.Error, data.Findings = err.Error(), findings
+			render(w, templates, "edit.html", status, data)
+			return
+		}
+
+		pushed, err := pushGistContext(r.Context(), gist, gist)
+		if err != nil {
+			data.Error = err.Error()
+			render(w, templates, "edit.html", upstreamStatus(err), data)
+			return
+		}
+		http.Redirect(w, r, "/gists/"+pushed.ID, http.StatusSeeOther)
+	}
+}
+
+//formFiles returns the files of the form of r, one for every filename and content field. Rows left blank are
+// skipped, and files gist already has are marked Existing.
+func formFiles(r *http.Request, gist *gists.GistFile) []formFile {
+	names, contents := r.PostForm["filename"], r.PostForm["content"]
+	var files []formFile
+	for i, name := range names {
+		content := ""
+		if i < len(contents) {
+			content = contents[i]
+		}
+		name = strings.TrimSpace(name)
+		if name == "" && strings.TrimSpace(content) == "" {
+			continue
+		}
+		f := formFile{Filename: name, Content: content}
+		for _, existing := range gist.Files {
+			f.Existing = f.Existing || existing.Filename == name
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// RemoveGistHandler deletes the gist identified by the id in the path and returns to the dashboard
+func RemoveGistHandler(w http.ResponseWriter, r *http.Request, session *auth.WebSession) {
+	resp, err := (&gists.GistFile{}).DeleteContext(r.Context(), mux2.Vars(r)["id"])
+	if err != nil {
+		uiError(w, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		uiError(w, &statusError{code: resp.StatusCode, status: resp.Status})
+		return
+	}
+	http.Redirect(w, r, "/gists", http.StatusSeeOther)
+}
+
+// StarGistHandler stars, or unstars, the gist identified by the id in the path. It returns to the dashboard if the
+// form says so with next=/gists, and to the gist otherwise.
+func StarGistHandler(starred bool) uiHandler {
+	return func(w http.ResponseWriter, r *http.Request, session *auth.WebSession) {
+		id := mux2.Vars(r)["id"]
+		if err := gists.StarContext(r.Context(), id, starred); err != nil {
+			uiError(w, err)
+			return
+		}
+		next := "/gists/" + id
+		if r.FormValue("next") == "/gists" {
+			next = "/gists"
+		}
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
+//render executes the template called name with data and writes it with the given status. Nothing is written but an
+// error if the template fails.
+func render(w http.ResponseWriter, templates *template.Template, name string, status int, data interface{}) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Print(err)
+		http.Error(w, fmt.Sprintf("could not render %s", name), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+//uiError writes the error of a request to the GitHub API that failed as a plain page, with the status upstreamError
+// would answer with
+func uiError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), upstreamStatus(err))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	mux2 "github.com/gorilla/mux"
+	"github.com/martinomburajr/gist/auth"
+	"github.com/martinomburajr/gist/config"
+	"github.com/martinomburajr/gist/secrets"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_routeUI(t *testing.T) {
+	defer fakeGitHub("alice-token")()
+	templates, err := parseTemplates(publicUI(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner, err := secrets.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, cookie := loggedIn(t, "alice-token")
+	mux := mux2.NewRouter()
+	routeUI(mux, templates, &publishPolicy{settings: &config.Settings{}, scanner: scanner}, sessions)
+
+	key := `"AKIA` + `IOSFODNN7EXAMPLE"`
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		form         url.Values
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}{
+		{"dashboard", http.MethodGet, "/gists", nil, http.StatusOK, `<a href="/gists/abc">notes</a>`, ""},
+		{"gist", http.MethodGet, "/gists/abc", nil, http.StatusOK, `action="/gists/abc/unstar"`, ""},
+		{"missing gist", http.MethodGet, "/gists/def", nil, http.StatusNotFound, "404", ""},
+		{"new", http.MethodGet, "/gists/new", nil, http.StatusOK, `action="/gists"`, ""},
+		{"edit", http.MethodGet, "/gists/abc/edit", nil, http.StatusOK, `value="a.txt" readonly`, ""},
+		{"add file", http.MethodPost, "/gists", url.Values{"action": {"add"}, "filename": {"a.go"},
+			"content": {"package a"}}, http.StatusOK, `name="filename" value=""`, ""},
+		{"preview", http.MethodPost, "/gists", url.Values{"action": {"preview"}, "filename": {"a.go"},
+			"content": {"package a"}}, http.StatusOK, `<h2>Preview</h2>`, ""},
+		{"create", http.MethodPost, "/gists", url.Values{"action": {"save"}, "filename": {"a.go"},
+			"content": {"package a"}}, http.StatusSeeOther, "", "/gists/new"},
+		{"create without files", http.MethodPost, "/gists", url.Values{"action": {"save"}, "filename": {""},
+			"content": {""}}, http.StatusBadRequest, "a gist needs at least one file", ""},
+		{"create public with a secret", http.MethodPost, "/gists", url.Values{"action": {"save"}, "public": {"true"},
+			"filename": {"a.go"}, "content": {"key := " + key}}, http.StatusUnprocessableEntity,
+			"aws-access-key-id", ""},
+		{"update", http.MethodPost, "/gists/abc", url.Values{"action": {"save"}, "description": {"more notes"},
+			"filename": {"a.txt"}, "content": {"b"}}, http.StatusSeeOther, "", "/gists/abc"},
+		{"delete", http.MethodPost, "/gists/abc/delete", nil, http.StatusSeeOther, "", "/gists"},
+		{"star", http.MethodPost, "/gists/abc/star", nil, http.StatusSeeOther, "", "/gists/abc"},
+		{"unstar from the dashboard", http.MethodPost, "/gists/abc/unstar", url.Values{"next": {"/gists"}},
+			http.StatusSeeOther, "", "/gists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(cookie)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s = %s, want it to contain %s", tt.method, tt.path, recorder.Body, tt.wantBody)
+			}
+			if got := recorder.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("%s %s redirected to %q, want %q", tt.method, tt.path, got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func Test_routeUI_loggedOut(t *testing.T) {
+	templates, err := parseTemplates(publicUI(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := mux2.NewRouter()
+	routeUI(mux, templates, &publishPolicy{settings: &config.Settings{}}, auth.NewSessions(auth.NewMemoryStore()))
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/gists", nil))
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/" {
+		t.Errorf("GET /gists = %d to %q, want a redirect to the login page", recorder.Code,
+			recorder.Header().Get("Location"))
+	}
+}
+
+func Test_highlight(t *testing.T) {
+	got := string(highlight("main.go", "package main // <b>"))
+	if !strings.Contains(got, "package") || strings.Contains(got, "<b>") {
+		t.Errorf("highlight() = %s, want the escaped code highlighted", got)
+	}
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//IgnoreFiles are the files, read from every directory that is scanned, whose gitignore syntax patterns exclude paths
+// from a scan. Patterns in a .gistignore are applied after, and so take precedence over, those in a .gitignore.
+var IgnoreFiles = []string{".gitignore", ".gistignore"}
+
+//ignoreRule is a single pattern line from an ignore file
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+//ignoreList holds the rules declared in the ignore files of a single directory. base is the slash separated path of
+// that directory relative to the scanned root, and the rules only apply to paths beneath it.
+type ignoreList struct {
+	base  string
+	rules []ignoreRule
+}
+
+//readIgnoreList reads the IgnoreFiles in dir. It returns nil if the directory contains none of them.
+func readIgnoreList(dir, base string) (*ignoreList, error) {
+	var list *ignoreList
+	for _, name := range IgnoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rules, err := parseIgnoreRules(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s -> %s", filepath.Join(dir, name), err)
+		}
+		if list == nil {
+			list = &ignoreList{base: base}
+		}
+		list.rules = append(list.rules, rules...)
+	}
+	return list, nil
+}
+
+//parseIgnoreRules parses the lines of an ignore file. Blank lines and lines starting with # are skipped.
+func parseIgnoreRules(r io.Reader) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := compileIgnoreRule(line)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+//compileIgnoreRule turns a single gitignore pattern into an ignoreRule.
+func compileIgnoreRule(line string) (ignoreRule, error) {
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	pattern, err := compileGlob(line)
+	if err != nil {
+		return rule, err
+	}
+	rule.pattern = pattern
+	return rule, nil
+}
+
+//compileGlob converts a gitignore style glob into a regular expression matched against slash separated relative
+// paths. A glob without a slash matches a name at any depth, a glob containing one is anchored to the base
+// directory. * and ? do not match a slash, ** matches any number of directories.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
+
+//ignored reports whether rel, a slash separated path relative to the scanned root, is excluded by the lists, which
+// must be ordered from the root downwards. The last matching rule wins.
+func ignored(lists []*ignoreList, rel string, isDir bool) bool {
+	result := false
+	for _, list := range lists {
+		sub := rel
+		if list.base != "" {
+			if !strings.HasPrefix(rel, list.base+"/") {
+				continue
+			}
+			sub = strings.TrimPrefix(rel, list.base+"/")
+		}
+		for _, rule := range list.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.pattern.MatchString(sub) {
+				result = !rule.negate
+			}
+		}
+	}
+	return result
+}
+
+//matchAny reports whether rel matches any of the rules. Negation is not meaningful here and is ignored.
+func matchAny(rules []ignoreRule, rel string, isDir bool) bool {
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,11 +1,16 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/martinomburajr/gist/config"
 	"github.com/martinomburajr/gist/gists"
+	"github.com/martinomburajr/gist/secrets"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -28,36 +33,347 @@ func SendAllGistFiles(gistss []*gists.GistFile) (chan *gists.GistFile, chan *htt
 	return failedChan, responses
 }
 
-//ScanAllFilesInDir checks to see if files in a given directory are gistable and resturns only the gistable ones
-func ScanAllFilesInDir(dir string) []*gists.GistFile {
-	filepaths := GetAllFilesInDir(dir)
+//ScanOptions controls which files a directory scan returns. The zero value returns every text file at any depth,
+// honouring the IgnoreFiles found along the way.
+type ScanOptions struct {
+	//Include restricts the scan to files matching at least one of these gitignore style globs. Every file is included
+	// if it is empty.
+	Include []string
+
+	//Exclude skips the files and directories matching any of these gitignore style globs.
+	Exclude []string
+
+	//MaxDepth limits how deep the scan descends. Files directly inside the scanned directory have a depth of 1. Zero
+	// means there is no limit.
+	MaxDepth int
+
+	//FollowSymlinks makes the scan follow symlinked files and directories, which are skipped otherwise. A directory
+	// reached more than once, such as through a symlink loop, is only scanned the first time.
+	FollowSymlinks bool
+
+	//NoIgnoreFiles disables the use of IgnoreFiles.
+	NoIgnoreFiles bool
+
+	//Strip is passed on to the GistParser of every file, see gists.GistParser.Strip
+	Strip bool
+
+	//Filename is passed on to the GistParser of every file, see gists.GistParser.Filename
+	Filename string
+
+	//Dedent is passed on to the GistParser of every file, see gists.GistParser.Dedent
+	Dedent bool
+
+	//Redact is passed on to the GistParser of every file, see gists.GistParser.Redact
+	Redact bool
+
+	//Public is passed on to the GistParser of every file, see gists.GistParser.Public
+	Public bool
+
+	//Secrets redacts the gists of the files that ask for it, a Scanner with the built in detectors is used if it is
+	// nil
+	Secrets *secrets.Scanner
+}
+
+//ScanStatus describes the outcome of checking whether a single file is gistable
+type ScanStatus string
+
+const (
+	//ScanGistable means the file has a GOGIST header and was converted to a GistFile
+	ScanGistable ScanStatus = "gistable"
+
+	//ScanNotGistable means the file lacks the 'start GOGIST' or 'end GOGIST' labels
+	ScanNotGistable ScanStatus = "not-gistable"
+
+	//ScanInvalid means the file has a GOGIST header but its metadata could not be parsed, e.g. a malformed public value
+	ScanInvalid ScanStatus = "invalid"
+
+	//ScanUnreadable means the file could not be read
+	ScanUnreadable ScanStatus = "unreadable"
+)
+
+//ScanResult records why a single candidate file was or was not gistable
+type ScanResult struct {
+	Path   string     `json:"path"`
+	Status ScanStatus `json:"status"`
+
+	//Error is the error returned by the GistParser, it is empty for gistable files
+	Error string `json:"error,omitempty"`
+
+	//Diagnostics lists the problems found in the GOGIST header, including warnings that did not stop the file from
+	// being gistable
+	Diagnostics []gists.Diagnostic `json:"diagnostics,omitempty"`
+
+	//Redactions lists the substitutions made in the gist of a file that asked for redaction, see
+	// secrets.Scanner.Redact
+	Redactions []secrets.Redaction `json:"redactions,omitempty"`
+
+	//Encrypt is how the gist of a gistable file is encrypted before it is published, see gists.GistParser.GetEncrypt
+	Encrypt string `json:"encrypt,omitempty"`
+
+	//Gist is the parsed gist, it is only set for gistable files
+	Gist *gists.GistFile `json:"-"`
+}
+
+//ScanReport lists every candidate file found by ScanAllFilesInDir together with its ScanStatus
+type ScanReport struct {
+	Dir     string        `json:"dir"`
+	Results []*ScanResult `json:"results"`
+}
+
+//Gistable returns the gists of the files that are gistable
+func (r *ScanReport) Gistable() []*gists.GistFile {
 	gistfiles := make([]*gists.GistFile, 0)
+	for _, result := range r.Results {
+		if result.Status == ScanGistable {
+			gistfiles = append(gistfiles, result.Gist)
+		}
+	}
+	return gistfiles
+}
+
+//Count returns the number of results with the given status
+func (r *ScanReport) Count(status ScanStatus) int {
+	count := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+//ScanAllFilesInDir checks to see if files in a given directory are gistable. The report lists every candidate file
+// returned by GetAllFilesInDir along with whether it is gistable and, if not, the error explaining why.
+func ScanAllFilesInDir(dir string, opts *ScanOptions) (*ScanReport, error) {
+	filepaths, err := GetAllFilesInDir(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScanReport{
+		Dir:     dir,
+		Results: make([]*ScanResult, 0, len(filepaths)),
+	}
 	for _, v := range filepaths {
-		gist := gists.GistParser{
-			Filepath: v,
+		report.Results = append(report.Results, ScanFile(v, opts))
+	}
+	return report, nil
+}
+
+//ScanFile checks whether a single file is gistable. opts may be nil.
+func ScanFile(path string, opts *ScanOptions) *ScanResult {
+	if opts == nil {
+		opts = &ScanOptions{}
+	}
+	result := &ScanResult{Path: path}
+	gist := gists.GistParser{
+		Filepath: path,
+		Strip:    opts.Strip,
+		Filename: opts.Filename,
+		Dedent:   opts.Dedent,
+		Redact:   opts.Redact,
+		Public:   opts.Public,
+	}
+
+	if err := gist.Reader(); err != nil {
+		result.Status = ScanUnreadable
+		result.Error = err.Error()
+		return result
+	}
+	if err := gist.IsGistable(); err != nil {
+		result.Status = ScanNotGistable
+		result.Error = err.Error()
+		return result
+	}
+
+	if header, _ := gist.Header(); header != nil {
+		result.Diagnostics = header.Diagnostics
+	}
+
+	gistFile, err := gist.ToGist()
+	if err != nil {
+		result.Status = ScanInvalid
+		result.Error = err.Error()
+		return result
+	}
+	redact, err := gist.GetRedact()
+	if err == nil {
+		result.Encrypt, err = gist.GetEncrypt()
+	}
+	if err != nil {
+		result.Status = ScanInvalid
+		result.Error = err.Error()
+		return result
+	}
+	if redact {
+		scanner := opts.Secrets
+		if scanner == nil {
+			if scanner, err = secrets.New(nil); err != nil {
+				result.Status = ScanInvalid
+				result.Error = err.Error()
+				return result
+			}
 		}
-		gistFile, err := gist.ToGist()
+		source, err := ioutil.ReadFile(path)
 		if err != nil {
+			result.Status = ScanUnreadable
+			result.Error = err.Error()
+			return result
+		}
+		result.Redactions = scanner.Redact(path, source, gistFile)
+	}
+	result.Status = ScanGistable
+	result.Gist = gistFile
+	return result
+}
 
-		} else {
-			gistfiles = append(gistfiles, gistFile)
+//GetAllFilesInDir returns the text files in a given directory and its subdirectories, in lexical order. .git and
+// config.DirName directories, binary files, sidecar files (see gists.SidecarSuffix), remote versions saved by sync
+// (see gists.RemoteSuffix) and anything excluded by opts or by IgnoreFiles are skipped. Files that cannot be read are
+// listed, so that ScanFile reports them as unreadable. opts may be nil.
+func GetAllFilesInDir(dir string, opts *ScanOptions) ([]string, error) {
+	w := &walker{visited: map[string]bool{}}
+	if opts != nil {
+		w.opts = *opts
+	}
+
+	for _, glob := range w.opts.Include {
+		rule, err := compileIgnoreRule(glob)
+		if err != nil {
+			return nil, fmt.Errorf("bad include pattern %q -> %s", glob, err)
 		}
+		w.include = append(w.include, rule)
 	}
-	return gistfiles
+	for _, glob := range w.opts.Exclude {
+		rule, err := compileIgnoreRule(glob)
+		if err != nil {
+			return nil, fmt.Errorf("bad exclude pattern %q -> %s", glob, err)
+		}
+		w.exclude = append(w.exclude, rule)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if err := w.walk(dir, "", 0, nil); err != nil {
+		return nil, err
+	}
+	return w.files, nil
 }
 
-//GetAllFilesInDir returns a list of files in a given directory
-func GetAllFilesInDir(dir string) []string {
-	var files []string
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		files = append(files, path)
+//walker holds the state of a single GetAllFilesInDir call
+type walker struct {
+	opts             ScanOptions
+	include, exclude []ignoreRule
+	visited          map[string]bool
+	files            []string
+}
+
+//walk scans dir, whose slash separated path relative to the root is rel, and recurses into its subdirectories. lists
+// holds the ignore rules of every directory above dir.
+func (w *walker) walk(dir, rel string, depth int, lists []*ignoreList) error {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if real, err = filepath.Abs(real); err != nil {
+		return err
+	}
+	if w.visited[real] {
 		return nil
-	})
+	}
+	w.visited[real] = true
+
+	if !w.opts.NoIgnoreFiles {
+		list, err := readIgnoreList(dir, rel)
+		if err != nil {
+			return err
+		}
+		if list != nil {
+			lists = append(lists[:len(lists):len(lists)], list)
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("could not read directory -> %s", err)
+	}
+
+	for _, info := range entries {
+		childPath := filepath.Join(dir, info.Name())
+		childRel := path.Join(rel, info.Name())
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !w.opts.FollowSymlinks {
+				continue
+			}
+			if info, err = os.Stat(childPath); err != nil {
+				continue
+			}
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" || info.Name() == config.DirName || ignored(lists, childRel, true) ||
+				matchAny(w.exclude, childRel, true) {
+				continue
+			}
+			if w.opts.MaxDepth > 0 && depth+1 >= w.opts.MaxDepth {
+				continue
+			}
+			if err := w.walk(childPath, childRel, depth+1, lists); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !info.Mode().IsRegular() || ignored(lists, childRel, false) || matchAny(w.exclude, childRel, false) {
+			continue
+		}
+		if strings.HasSuffix(info.Name(), gists.SidecarSuffix) || isRemoteVersion(childPath) {
+			continue
+		}
+		if len(w.include) > 0 && !matchAny(w.include, childRel, false) {
+			continue
+		}
+
+		binary, err := isBinary(childPath)
+		if err != nil || !binary {
+			w.files = append(w.files, childPath)
+		}
+	}
+	return nil
+}
+
+//isRemoteVersion reports whether the file at path is the remote version of another file, saved next to it by sync
+func isRemoteVersion(path string) bool {
+	if !strings.HasSuffix(path, gists.RemoteSuffix) {
+		return false
+	}
+	_, err := os.Stat(strings.TrimSuffix(path, gists.RemoteSuffix))
+	return err == nil
+}
+
+//openFile opens the files isBinary reads, tests replace it to simulate unreadable files
+var openFile = os.Open
+
+//isBinary reports whether the file at path looks like a binary file, i.e. it has a NUL byte within its first few
+// kilobytes, the same heuristic git uses.
+func isBinary(path string) (bool, error) {
+	f, err := openFile(path)
 	if err != nil {
-		panic(err)
+		return false, err
 	}
-	for _, file := range files {
-		fmt.Println(file)
+	defer f.Close()
+
+	buf := make([]byte, 8000)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
 	}
-	return files
-}
\ No newline at end of file
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}
@@ -0,0 +1,109 @@
+package watch
+
+import (
+	"github.com/martinomburajr/gist/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+//inotifyMask selects the inotify events that change the content of a file, or the files in a directory
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+//inotify reports the changes to the files within a directory tree, watching every directory of it
+type inotify struct {
+	fd   int
+	file *os.File
+
+	mu      sync.Mutex
+	watches map[int32]string
+}
+
+//notifyChanges has inotify send the path of every file changed within dir, and returns the function stopping it
+func notifyChanges(dir string, send func(path string) bool) (func() error, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, errNotSupported
+	}
+
+	//a non blocking file is read through the runtime's poller, so closing it ends a pending Read
+	n := &inotify{fd: fd, file: os.NewFile(uintptr(fd), "inotify"), watches: map[int32]string{}}
+	if err := n.addTree(dir, nil); err != nil {
+		n.file.Close()
+		return nil, err
+	}
+	go n.read(send)
+	return n.file.Close, nil
+}
+
+//addTree watches dir and the directories within it, except .git and config.DirName directories. Files found are
+// sent if send is set, for directories created after watching started.
+func (n *inotify) addTree(dir string, send func(path string) bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			//the directory may be removed as it is walked
+			return nil
+		}
+		if !info.IsDir() {
+			if send != nil && !send(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path != dir && (info.Name() == ".git" || info.Name() == config.DirName) {
+			return filepath.SkipDir
+		}
+
+		wd, err := syscall.InotifyAddWatch(n.fd, path, inotifyMask)
+		if err != nil {
+			return err
+		}
+		n.mu.Lock()
+		n.watches[int32(wd)] = path
+		n.mu.Unlock()
+		return nil
+	})
+}
+
+//read sends the paths of the files the events read from inotify refer to, until the inotify file is closed
+func (n *inotify) read(send func(path string) bool) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= count; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			offset = start + int(event.Len)
+			name := strings.TrimRight(string(buf[start:offset]), "\x00")
+
+			n.mu.Lock()
+			dir, ok := n.watches[event.Wd]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(n.watches, event.Wd)
+			}
+			n.mu.Unlock()
+			if !ok || name == "" {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			if event.Mask&syscall.IN_ISDIR != 0 {
+				if event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					n.addTree(path, send)
+				}
+				continue
+			}
+			if !send(path) {
+				return
+			}
+		}
+	}
+}
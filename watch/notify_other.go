@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package watch
+
+//notifyChanges is only implemented on Linux, changes are polled for elsewhere
+func notifyChanges(dir string, send func(path string) bool) (func() error, error) {
+	return nil, errNotSupported
+}
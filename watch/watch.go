@@ -0,0 +1,193 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+//errNotSupported is returned by notifyChanges where the operating system cannot report changes
+var errNotSupported = errors.New("watching for changes is not supported")
+
+//Options configure a Watcher
+type Options struct {
+	//Interval is how often the files are polled for changes
+	Interval time.Duration
+
+	//Debounce is how long the files must be left alone after a change before it is reported, so a burst of writes,
+	// e.g. an editor saving through a temporary file, is reported once
+	Debounce time.Duration
+
+	//Poll polls for changes even where the operating system can report them itself
+	Poll bool
+
+	//Files lists the files to poll, it is called on every poll so new files are picked up. Changes reported by the
+	// operating system are not filtered by it.
+	Files func() ([]string, error)
+}
+
+//Watcher reports changes to the files within a directory. Changes are reported on Changes in batches, once the files
+// have been left alone for Options.Debounce, so a file written several times in a row is reported once. Changes are
+// polled for unless the operating system can report them, see Options.Poll.
+type Watcher struct {
+	//Changes receives the sorted paths of the files created, written or removed since the last batch. It is closed
+	// by Close.
+	Changes <-chan []string
+
+	//Errors receives the errors met while watching, e.g. from Options.Files, watching carries on regardless
+	Errors <-chan error
+
+	opts    Options
+	changes chan []string
+	errors  chan error
+	notify  chan string
+	done    chan struct{}
+	once    sync.Once
+	stop    func() error
+}
+
+//New starts watching dir
+func New(dir string, opts Options) (*Watcher, error) {
+	w := &Watcher{
+		opts:    opts,
+		changes: make(chan []string),
+		errors:  make(chan error, 1),
+		notify:  make(chan string),
+		done:    make(chan struct{}),
+	}
+	w.Changes = w.changes
+	w.Errors = w.errors
+
+	var err error
+	if !opts.Poll {
+		w.stop, err = notifyChanges(dir, w.send)
+	}
+	if opts.Poll || err == errNotSupported {
+		w.stop, err = w.poll()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	go w.debounce()
+	return w, nil
+}
+
+//Close stops watching and closes Changes
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.stop()
+	})
+	return err
+}
+
+//send reports a change to the file at path, it returns false once the watcher is closed
+func (w *Watcher) send(path string) bool {
+	select {
+	case w.notify <- path:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+//fail reports err unless an error is already waiting to be received
+func (w *Watcher) fail(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
+
+//debounce collects the changes sent until none have been sent for Options.Debounce, and passes them on as a batch.
+func (w *Watcher) debounce() {
+	defer close(w.changes)
+
+	pending := map[string]bool{}
+	timer := time.NewTimer(w.opts.Debounce)
+	timer.Stop()
+	for {
+		select {
+		case path := <-w.notify:
+			pending[path] = true
+			timer.Reset(w.opts.Debounce)
+		case <-timer.C:
+			batch := make([]string, 0, len(pending))
+			for path := range pending {
+				batch = append(batch, path)
+			}
+			sort.Strings(batch)
+			pending = map[string]bool{}
+			select {
+			case w.changes <- batch:
+			case <-w.done:
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+//fileState is what poll compares to tell whether a file changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+//poll starts polling the files listed by Options.Files every Options.Interval, and returns the function stopping it
+func (w *Watcher) poll() (func() error, error) {
+	snapshot := func() map[string]fileState {
+		files, err := w.opts.Files()
+		if err != nil {
+			w.fail(err)
+			return nil
+		}
+		states := make(map[string]fileState, len(files))
+		for _, path := range files {
+			if info, err := os.Stat(path); err == nil {
+				states[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			}
+		}
+		return states
+	}
+
+	previous := snapshot()
+	ticker := time.NewTicker(w.opts.Interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+			case <-w.done:
+				return
+			}
+
+			current := snapshot()
+			if current == nil {
+				continue
+			}
+			for path, state := range current {
+				if old, ok := previous[path]; !ok || old != state {
+					if !w.send(path) {
+						return
+					}
+				}
+			}
+			for path := range previous {
+				if _, ok := current[path]; !ok && !w.send(path) {
+					return
+				}
+			}
+			previous = current
+		}
+	}()
+
+	return func() error {
+		ticker.Stop()
+		return nil
+	}, nil
+}
@@ -0,0 +1,77 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	tests := []struct {
+		name string
+		poll bool
+	}{
+		{"poll", true},
+		{"notify", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "watch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "main.go")
+			if err := ioutil.WriteFile(path, []byte("package main\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := New(dir, Options{
+				Interval: 10 * time.Millisecond,
+				Debounce: 100 * time.Millisecond,
+				Poll:     tt.poll,
+				Files: func() ([]string, error) {
+					return []string{path}, nil
+				},
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			defer w.Close()
+
+			//a burst of writes is reported once
+			for i := 0; i < 5; i++ {
+				content := []byte("package main\n" + string(make([]byte, i)))
+				if err := ioutil.WriteFile(path, content, 0600); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			select {
+			case got := <-w.Changes:
+				if !reflect.DeepEqual(got, []string{path}) {
+					t.Errorf("Watcher.Changes = %v, want [%s]", got, path)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Watcher.Changes received nothing")
+			}
+			select {
+			case got := <-w.Changes:
+				t.Errorf("Watcher.Changes = %v after the burst, want nothing", got)
+			case <-time.After(300 * time.Millisecond):
+			}
+
+			if err := w.Close(); err != nil {
+				t.Errorf("Watcher.Close() error = %v", err)
+			}
+			if _, ok := <-w.Changes; ok {
+				t.Errorf("Watcher.Changes is still open after Close")
+			}
+		})
+	}
+}